@@ -0,0 +1,161 @@
+// 认证相关的中间件: BasicAuth, Bearer Token, API Key
+package gee
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// 认证成功后, 用户(principal)存放在上下文中的key
+const AuthUserKey = "user"
+
+var (
+	// 凭证缺失或者无效, 返回401
+	ErrUnauthorized = errors.New("gee: unauthorized")
+	// 凭证有效但是没有权限, 返回403
+	ErrForbidden = errors.New("gee: forbidden")
+)
+
+// 用户名 -> 密码
+type Accounts map[string]string
+
+// 校验token, 返回认证后的用户
+// 返回ErrForbidden时响应403, 其他错误都响应401
+type TokenValidator func(c *Context, token string) (interface{}, error)
+
+// 使用默认realm的BasicAuth
+func BasicAuth(accounts Accounts) HandlerFunc {
+	return BasicAuthForRealm(accounts, "")
+}
+
+// BasicAuth中间件, 认证成功后用户名存放在 c.Keys[AuthUserKey]
+func BasicAuthForRealm(accounts Accounts, realm string) HandlerFunc {
+	if realm == "" {
+		realm = "Authorization Required"
+	}
+	if len(accounts) == 0 {
+		panic("gee: BasicAuth needs at least one account")
+	}
+	// 预先计算好摘要, 比较的时候长度固定, 不会因为长度不同提前返回
+	type pair struct {
+		user     string
+		userHash [sha256.Size]byte
+		passHash [sha256.Size]byte
+	}
+	pairs := make([]pair, 0, len(accounts))
+	for user, pass := range accounts {
+		if user == "" {
+			panic("gee: BasicAuth user can not be empty")
+		}
+		pairs = append(pairs, pair{user, sha256.Sum256([]byte(user)), sha256.Sum256([]byte(pass))})
+	}
+	challenge := "Basic realm=" + strconv.Quote(realm) + `, charset="UTF-8"`
+
+	return func(c *Context) {
+		user, pass, ok := c.Req.BasicAuth()
+		found := ""
+		if ok {
+			userHash := sha256.Sum256([]byte(user))
+			passHash := sha256.Sum256([]byte(pass))
+			// 遍历所有账号, 不提前退出, 避免通过耗时猜测用户名
+			for _, p := range pairs {
+				match := subtle.ConstantTimeCompare(userHash[:], p.userHash[:]) &
+					subtle.ConstantTimeCompare(passHash[:], p.passHash[:])
+				if match == 1 {
+					found = p.user
+				}
+			}
+		}
+		if found == "" {
+			c.SetHeader("WWW-Authenticate", challenge)
+			c.Fail(http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			return
+		}
+		c.Set(AuthUserKey, found)
+		c.Next()
+	}
+}
+
+// Bearer Token中间件, 从 Authorization: Bearer <token> 中取出token交给validator校验
+func BearerAuth(validator TokenValidator) HandlerFunc {
+	if validator == nil {
+		panic("gee: BearerAuth needs a validator")
+	}
+	return func(c *Context) {
+		token, ok := bearerToken(c)
+		if !ok {
+			c.SetHeader("WWW-Authenticate", `Bearer realm="gee"`)
+			c.Fail(http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			return
+		}
+		principal, err := validator(c, token)
+		if err != nil {
+			if errors.Is(err, ErrForbidden) {
+				c.SetHeader("WWW-Authenticate", `Bearer realm="gee", error="insufficient_scope"`)
+				c.Fail(http.StatusForbidden, http.StatusText(http.StatusForbidden))
+				return
+			}
+			c.SetHeader("WWW-Authenticate", `Bearer realm="gee", error="invalid_token"`)
+			c.Fail(http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			return
+		}
+		c.Set(AuthUserKey, principal)
+		c.Next()
+	}
+}
+
+// API Key中间件的配置
+type APIKeyConfig struct {
+	// 从哪个请求头读取, 默认 X-API-Key
+	Header string
+	// 请求头里没有时从哪个query参数读取, 为空表示不从query读取
+	Query string
+	// 校验key, 必填
+	Validator TokenValidator
+}
+
+// API Key中间件, 先找请求头再找query参数
+func APIKey(config APIKeyConfig) HandlerFunc {
+	if config.Validator == nil {
+		panic("gee: APIKey needs a validator")
+	}
+	if config.Header == "" {
+		config.Header = "X-API-Key"
+	}
+	return func(c *Context) {
+		key := c.Req.Header.Get(config.Header)
+		if key == "" && config.Query != "" {
+			key = c.Query(config.Query)
+		}
+		if key == "" {
+			c.Fail(http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			return
+		}
+		principal, err := config.Validator(c, key)
+		if err != nil {
+			if errors.Is(err, ErrForbidden) {
+				c.Fail(http.StatusForbidden, http.StatusText(http.StatusForbidden))
+				return
+			}
+			c.Fail(http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			return
+		}
+		c.Set(AuthUserKey, principal)
+		c.Next()
+	}
+}
+
+// 从Authorization请求头中取出Bearer token
+func bearerToken(c *Context) (string, bool) {
+	auth := c.Req.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return "", false
+	}
+	token := strings.TrimSpace(auth[len(prefix):])
+	return token, token != ""
+}
@@ -0,0 +1,110 @@
+package gee
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBasicAuth(t *testing.T) {
+	r := New()
+	r.Use(BasicAuth(Accounts{"geektutu": "1234"}))
+	r.GET("/admin", func(c *Context) {
+		c.String(http.StatusOK, "hello %s", c.GetString(AuthUserKey))
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/admin", nil)
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Fatalf("expect 401 with challenge, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	req.SetBasicAuth("geektutu", "1234")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "hello geektutu" {
+		t.Fatalf("expect 200, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestBearerAuth(t *testing.T) {
+	r := New()
+	r.Use(BearerAuth(func(c *Context, token string) (interface{}, error) {
+		switch token {
+		case "good":
+			return "geektutu", nil
+		case "guest":
+			return nil, ErrForbidden
+		}
+		return nil, errors.New("bad token")
+	}))
+	r.GET("/", func(c *Context) {
+		c.String(http.StatusOK, "%v", c.MustGet(AuthUserKey))
+	})
+
+	cases := map[string]int{"": 401, "Bearer bad": 401, "Bearer guest": 403, "Bearer good": 200}
+	for auth, code := range cases {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		r.ServeHTTP(w, req)
+		if w.Code != code {
+			t.Fatalf("%q: expect %d, got %d", auth, code, w.Code)
+		}
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	validator := func(c *Context, key string) (interface{}, error) {
+		switch key {
+		case "good":
+			return "service", nil
+		case "readonly":
+			return nil, ErrForbidden
+		}
+		return nil, errors.New("unknown key")
+	}
+	r := New()
+	r.Group("/header").Use(APIKey(APIKeyConfig{Validator: validator}))
+	r.Group("/query").Use(APIKey(APIKeyConfig{Header: "X-Token", Query: "api_key", Validator: validator}))
+	handler := func(c *Context) {
+		c.String(http.StatusOK, "%v", c.MustGet(AuthUserKey))
+	}
+	r.GET("/header/", handler)
+	r.GET("/query/", handler)
+
+	tests := []struct {
+		path, header, value string
+		code                int
+	}{
+		{"/header/", "", "", http.StatusUnauthorized},
+		{"/header/", "X-API-Key", "good", http.StatusOK},
+		{"/header/", "X-API-Key", "bad", http.StatusUnauthorized},
+		{"/header/", "X-API-Key", "readonly", http.StatusForbidden},
+		// 没有配置Query时不从query读取
+		{"/header/?api_key=good", "", "", http.StatusUnauthorized},
+		{"/query/?api_key=good", "", "", http.StatusOK},
+		{"/query/?api_key=readonly", "", "", http.StatusForbidden},
+		// 请求头优先于query
+		{"/query/?api_key=good", "X-Token", "bad", http.StatusUnauthorized},
+		{"/query/", "X-Token", "good", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		if tt.header != "" {
+			req.Header.Set(tt.header, tt.value)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.code {
+			t.Fatalf("%s %s=%s: expect %d, got %d", tt.path, tt.header, tt.value, tt.code, w.Code)
+		}
+		if tt.code == http.StatusOK && w.Body.String() != "service" {
+			t.Fatalf("%s: expect principal in context, got %q", tt.path, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,261 @@
+// 获取客户端的真实IP, 只信任配置过的代理发来的转发请求头(Engine.RemoteIPHeaders)
+package gee
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// 默认使用的请求头, RFC 7239的Forwarded需要确认代理会覆盖客户端发来的值后再加入
+var defaultRemoteIPHeaders = []string{"X-Forwarded-For", "X-Real-IP"}
+
+// 设置可信的代理, 可以是IP或者CIDR, 例如 "10.0.0.0/8", "127.0.0.1", "::1"
+// 只有直接连接的地址是可信代理时, 才会使用代理设置的请求头
+// 默认不信任任何代理, 传入nil可以清空
+func (engine *Engine) SetTrustedProxies(proxies []string) error {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return fmt.Errorf("gee: invalid trusted proxy %q", proxy)
+			}
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			proxy = fmt.Sprintf("%s/%d", proxy, bits)
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return fmt.Errorf("gee: invalid trusted proxy %q: %w", proxy, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	engine.trustedProxies = nets
+	return nil
+}
+
+func (engine *Engine) isTrustedProxy(ip net.IP) bool {
+	if engine == nil || ip == nil {
+		return false
+	}
+	for _, ipNet := range engine.trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// 直接连接的地址, 不考虑任何请求头, unix socket等没有IP时返回空字符串
+func (c *Context) RemoteIP() string {
+	ip := c.remoteIP()
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+func (c *Context) remoteIP() net.IP {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(c.Req.RemoteAddr))
+	if err != nil {
+		host = c.Req.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// 直接连接的地址是否是可信代理
+func (c *Context) fromTrustedProxy() bool {
+	return c.engine.isTrustedProxy(c.remoteIP())
+}
+
+// 客户端的真实IP
+// 请求来自可信代理时, 按 Engine.RemoteIPHeaders 的顺序查找请求头
+// 多级代理时从右往左跳过可信代理, 第一个不可信的地址就是客户端
+// 其他情况返回 RemoteIP
+func (c *Context) ClientIP() string {
+	if c.fromTrustedProxy() {
+		for _, name := range c.engine.RemoteIPHeaders {
+			if strings.EqualFold(name, "Forwarded") {
+				if fwd := c.forwarded(); fwd != nil {
+					return net.ParseIP(fwd["for"]).String()
+				}
+				continue
+			}
+			if hops, i, ok := c.headerHops(name); ok {
+				return net.ParseIP(hops[i]).String()
+			}
+		}
+	}
+	return c.RemoteIP()
+}
+
+// 是否信任某个代理设置的请求头
+func (c *Context) trustsHeader(name string) bool {
+	for _, h := range c.engine.RemoteIPHeaders {
+		if strings.EqualFold(h, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// 客户端使用的协议, http 或 https
+func (c *Context) Scheme() string {
+	if c.fromTrustedProxy() {
+		if proto := c.forwardedValue("proto", "X-Forwarded-Proto"); proto != "" {
+			return strings.ToLower(proto)
+		}
+	}
+	if c.Req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// 客户端请求的Host, 可能带有端口
+func (c *Context) Host() string {
+	if c.fromTrustedProxy() {
+		if host := c.forwardedValue("host", "X-Forwarded-Host"); host != "" {
+			return host
+		}
+	}
+	return c.Req.Host
+}
+
+// 可信代理转发的proto或host
+// 启用了Forwarded时使用客户端那一跳的参数
+// 否则使用 X-Forwarded-Proto / X-Forwarded-Host 中和 X-Forwarded-For 里客户端对应的那一项,
+// 代理是追加写入的, 最左边的值可能是客户端伪造的
+func (c *Context) forwardedValue(param string, header string) string {
+	if c.trustsHeader("Forwarded") {
+		if fwd := c.forwarded(); fwd != nil && fwd[param] != "" {
+			return fwd[param]
+		}
+	}
+	var values []string
+	for _, value := range c.Req.Header.Values(header) {
+		values = append(values, strings.Split(value, ",")...)
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	// 客户端右边有几个可信代理, 每个代理都会追加一项
+	offset := 0
+	if c.trustsHeader("X-Forwarded-For") {
+		if hops, i, ok := c.headerHops("X-Forwarded-For"); ok {
+			offset = len(hops) - 1 - i
+		}
+	}
+	i := len(values) - 1 - offset
+	if i < 0 {
+		// 不是每个代理都设置了这个头, 最左边的值也是代理写入的
+		i = 0
+	}
+	return strings.TrimSpace(values[i])
+}
+
+// 解析 X-Forwarded-For 这类逗号分隔的地址列表, 返回所有地址和客户端的下标
+func (c *Context) headerHops(name string) ([]string, int, bool) {
+	var hops []string
+	for _, value := range c.Req.Header.Values(name) {
+		for _, hop := range strings.Split(value, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	if len(hops) == 0 {
+		return nil, 0, false
+	}
+	i, ok := c.clientHop(len(hops), func(i int) net.IP {
+		return net.ParseIP(hops[i])
+	})
+	return hops, i, ok
+}
+
+// 在代理链中从右往左找到客户端, ip(i)返回第i跳的地址
+// 遇到无法解析的地址时返回false
+func (c *Context) clientHop(n int, ip func(i int) net.IP) (int, bool) {
+	for i := n - 1; i >= 0; i-- {
+		hop := ip(i)
+		if hop == nil {
+			return 0, false
+		}
+		if i == 0 || !c.engine.isTrustedProxy(hop) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// 解析RFC 7239的Forwarded请求头, 返回客户端那一跳的参数(for, proto, host, by)
+// for中的端口和IPv6的方括号会被去掉, 没有这个请求头或者格式错误时返回nil
+func (c *Context) forwarded() map[string]string {
+	var elements []map[string]string
+	for _, value := range c.Req.Header.Values("Forwarded") {
+		for _, element := range splitQuoted(value, ',') {
+			params := make(map[string]string)
+			for _, pair := range splitQuoted(element, ';') {
+				k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok {
+					return nil
+				}
+				params[strings.ToLower(k)] = unquote(v)
+			}
+			params["for"] = forwardedNode(params["for"])
+			elements = append(elements, params)
+		}
+	}
+	if len(elements) == 0 {
+		return nil
+	}
+	i, ok := c.clientHop(len(elements), func(i int) net.IP {
+		return net.ParseIP(elements[i]["for"])
+	})
+	if !ok {
+		return nil
+	}
+	return elements[i]
+}
+
+// 按sep切分, 忽略引号中的sep
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unquote(v string) string {
+	v = strings.TrimSpace(v)
+	if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+		v = strings.ReplaceAll(v[1:len(v)-1], `\`, "")
+	}
+	return v
+}
+
+// for=192.0.2.60:8080 或 for="[2001:db8::1]:4711", 只保留IP
+func forwardedNode(node string) string {
+	if strings.HasPrefix(node, "[") {
+		if i := strings.IndexByte(node, ']'); i > 0 {
+			return node[1:i]
+		}
+		return node
+	}
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return host
+	}
+	return node
+}
@@ -0,0 +1,115 @@
+package gee
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	r := New()
+	if err := r.SetTrustedProxies([]string{"10.0.0.0/8", "::1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetTrustedProxies([]string{"bad"}); err == nil {
+		t.Fatal("expect error for invalid proxy")
+	}
+
+	tests := []struct {
+		name, remote string
+		header       map[string]string
+		client       string
+	}{
+		{"direct", "1.2.3.4:1234", map[string]string{"X-Forwarded-For": "9.9.9.9"}, "1.2.3.4"},
+		{"xff", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "9.9.9.9, 1.2.3.4, 10.0.0.2"}, "1.2.3.4"},
+		{"xff all trusted", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"xff invalid", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "bad", "X-Real-IP": "5.6.7.8"}, "5.6.7.8"},
+		{"real ip", "[::1]:1234", map[string]string{"X-Real-IP": "2001:db8::1"}, "2001:db8::1"},
+		// 默认不信任Forwarded, 客户端发来的Forwarded会被代理原样转发
+		{"forwarded spoof", "10.0.0.1:1234", map[string]string{
+			"Forwarded":       "for=6.6.6.6",
+			"X-Forwarded-For": "6.6.6.6, 1.2.3.4",
+		}, "1.2.3.4"},
+		{"no header", "10.0.0.1:1234", nil, "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tt.remote
+		for k, v := range tt.header {
+			req.Header.Set(k, v)
+		}
+		c := r.CreateContext(httptest.NewRecorder(), req)
+		if got := c.ClientIP(); got != tt.client {
+			t.Errorf("%s: expect %s, got %s", tt.name, tt.client, got)
+		}
+	}
+}
+
+func TestClientIPForwarded(t *testing.T) {
+	r := New()
+	r.SetTrustedProxies([]string{"10.0.0.0/8"})
+	r.RemoteIPHeaders = []string{"Forwarded", "X-Forwarded-For"}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("Forwarded", `for=9.9.9.9, for="[2001:db8:cafe::17]:4711";proto=https, for=10.0.0.2`)
+	req.Header.Set("X-Forwarded-For", "1.1.1.1")
+	c := r.CreateContext(httptest.NewRecorder(), req)
+	if got := c.ClientIP(); got != "2001:db8:cafe::17" {
+		t.Fatalf("expect client from Forwarded, got %s", got)
+	}
+	if c.Scheme() != "https" {
+		t.Fatalf("expect proto from the client hop, got %s", c.Scheme())
+	}
+
+	// 只信任X-Real-IP时忽略其他请求头
+	r.RemoteIPHeaders = []string{"X-Real-IP"}
+	req.Header.Set("X-Real-IP", "5.6.7.8")
+	if got := c.ClientIP(); got != "5.6.7.8" {
+		t.Fatalf("expect X-Real-IP, got %s", got)
+	}
+}
+
+func TestSchemeAndHost(t *testing.T) {
+	r := New()
+	r.SetTrustedProxies([]string{"10.0.0.1"})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "1.2.3.4:1234"
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("X-Forwarded-Host", "evil.com")
+	c := r.CreateContext(httptest.NewRecorder(), req)
+	if c.Scheme() != "http" || c.Host() != "example.com" {
+		t.Fatalf("untrusted proxy: got %s %s", c.Scheme(), c.Host())
+	}
+
+	req.RemoteAddr = "10.0.0.1:1234"
+	if c.Scheme() != "https" || c.Host() != "evil.com" {
+		t.Fatalf("x-forwarded: got %s %s", c.Scheme(), c.Host())
+	}
+
+	// 代理追加写入, 最左边的值是客户端伪造的
+	req.Header.Set("X-Forwarded-For", "6.6.6.6, 1.2.3.4")
+	req.Header.Set("X-Forwarded-Proto", "https, http")
+	req.Header.Set("X-Forwarded-Host", "evil.com, api.example.com")
+	if c.Scheme() != "http" || c.Host() != "api.example.com" {
+		t.Fatalf("appended x-forwarded: got %s %s", c.Scheme(), c.Host())
+	}
+
+	// 没有启用时忽略Forwarded
+	req.Header.Set("Forwarded", `for=1.2.3.4;proto=HTTPS;host="other.example.com"`)
+	if c.Host() != "api.example.com" {
+		t.Fatalf("forwarded should be ignored by default, got %s", c.Host())
+	}
+	r.RemoteIPHeaders = []string{"Forwarded"}
+	if c.Scheme() != "https" || c.Host() != "other.example.com" {
+		t.Fatalf("forwarded: got %s %s", c.Scheme(), c.Host())
+	}
+
+	req = httptest.NewRequest("GET", "https://example.com/", nil)
+	req.TLS = &tls.ConnectionState{}
+	c = r.CreateContext(httptest.NewRecorder(), req)
+	if c.Scheme() != "https" {
+		t.Fatalf("tls: got %s", c.Scheme())
+	}
+}
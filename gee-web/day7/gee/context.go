@@ -4,9 +4,15 @@ package gee
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
+	"net/url"
+	"sync"
 )
 
+// 被终止后index会被设置为这个值, 保证后面的函数都不会再执行
+const abortIndex int = math.MaxInt32 / 2
+
 // 封装context结构体
 type Context struct {
 	// http.ResponseWriter
@@ -21,6 +27,8 @@ type Context struct {
 	StatusCode int
 	// 动态路由获取的参数
 	Params map[string]string
+	// 匹配到的路由规则, 例如 /users/:id
+	fullPath string
 	// 中间件
 	index int // 初始为-1
 	handlers []HandlerFunc
@@ -28,6 +36,13 @@ type Context struct {
 	// day6
 	// 使用engine的模板
 	engine *Engine
+
+	// 请求范围内的kv存储, 中间件之间通过它传递数据(例如认证后的用户)
+	Keys map[string]interface{}
+	mu   sync.RWMutex
+
+	// 通过 c.Error 记录的错误
+	Errors errorMsgs
 }
 
 // 构造函数
@@ -43,10 +58,60 @@ func NewContext(w http.ResponseWriter, req *http.Request) *Context {
 }
 
 func (c *Context) Fail (code int, err string) {
-	c.index = len(c.handlers)
+	c.Abort()
 	c.JSON(code, H{"msg": err})
 }
 
+// 终止后续的中间件和业务函数, 当前函数会继续执行完
+func (c *Context) Abort() {
+	c.index = abortIndex
+}
+
+// 终止并写入状态码
+func (c *Context) AbortWithStatus(code int) {
+	c.Abort()
+	c.Status(code)
+}
+
+// 是否已经被终止
+func (c *Context) IsAborted() bool {
+	return c.index >= abortIndex
+}
+
+// 向上下文里存放数据
+func (c *Context) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Keys == nil {
+		c.Keys = make(map[string]interface{})
+	}
+	c.Keys[key] = value
+}
+
+// 从上下文里取出数据
+func (c *Context) Get(key string) (value interface{}, exists bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	value, exists = c.Keys[key]
+	return
+}
+
+// 取出数据, 不存在时panic
+func (c *Context) MustGet(key string) interface{} {
+	if value, exists := c.Get(key); exists {
+		return value
+	}
+	panic("Key \"" + key + "\" does not exist")
+}
+
+// 以字符串形式取出数据
+func (c *Context) GetString(key string) (s string) {
+	if val, ok := c.Get(key); ok && val != nil {
+		s, _ = val.(string)
+	}
+	return
+}
+
 // 根据param获取对应参数
 func (c *Context) Param(key string) string {
 	val, _ := c.Params[key]
@@ -75,6 +140,43 @@ func (c *Context) SetHeader(key string, val string) {
 	c.Writer.Header().Set(key, val)
 }
 
+// 设置cookie, 必须在写入响应之前调用
+// 值会做url编码, 使用Cookie读取时会自动解码
+func (c *Context) SetCookie(cookie *http.Cookie) {
+	ck := *cookie
+	if ck.Path == "" {
+		ck.Path = "/"
+	}
+	ck.Value = url.QueryEscape(ck.Value)
+	http.SetCookie(c.Writer, &ck)
+}
+
+// 读取cookie, 不存在时返回 http.ErrNoCookie
+func (c *Context) Cookie(name string) (string, error) {
+	cookie, err := c.Req.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return url.QueryUnescape(cookie.Value)
+}
+
+// HTTP/2 server push, 提前推送页面需要的静态资源, 例如 Static 注册的 /assets/css/main.css
+// 不是HTTP/2连接或者客户端禁用了push时返回 http.ErrNotSupported
+func (c *Context) Push(target string, opts *http.PushOptions) error {
+	// 中间件可能包装了Writer, 一层层找到支持push的
+	w := c.Writer
+	for {
+		if pusher, ok := w.(http.Pusher); ok {
+			return pusher.Push(target, opts)
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return http.ErrNotSupported
+		}
+		w = u.Unwrap()
+	}
+}
+
 // 设置纯文本格式 Content-Type
 func (c *Context) String(code int, format string, vals ...interface{}) {
 	c.SetHeader("Content-Type", "text/plain") // 纯文本格式
@@ -87,6 +189,7 @@ func (c *Context) String(code int, format string, vals ...interface{}) {
 func (c *Context) JSON(code int, obj interface{}) {
 	// 设置响应头
 	c.SetHeader("Content-Type", "application/json") // 以json格式展示obj
+	c.Status(code)
 	// 得到encoder
 	encoder := json.NewEncoder(c.Writer)
 
@@ -101,11 +204,33 @@ func (c *Context) Data(code int, data []byte) {
 	c.Writer.Write(data)
 }
 
+// 匹配到的路由规则, 例如 /users/:id, 没有匹配到路由时为空
+// 比原始路径更适合作为日志和监控的维度
+func (c *Context) FullPath() string {
+	return c.fullPath
+}
+
+// 重定向, code必须是3xx, POST之后跳转页面时也可以用201
+// location可以是相对路径, 会根据当前请求的路径解析
+func (c *Context) Redirect(code int, location string) {
+	if (code < http.StatusMultipleChoices || code > http.StatusPermanentRedirect) && code != http.StatusCreated {
+		panic(fmt.Sprintf("gee: cannot redirect with status code %d", code))
+	}
+	c.StatusCode = code
+	http.Redirect(c.Writer, c.Req, location, code)
+}
+
 // 响应HTML数据
 func (c *Context) HTML(code int, name string, data interface{}) {
+	tmpl := c.engine.templates()
+	if tmpl == nil {
+		warnPrint("HTML(%q) is called before LoadHTMLGlob", name)
+		c.Fail(http.StatusInternalServerError, "html templates are not loaded")
+		return
+	}
 	c.SetHeader("Content-Type", "text/html") // 纯文本格式
 	c.Status(code)
-	if err := c.engine.htmlTemplates.ExecuteTemplate(c.Writer, name, data); err != nil {
+	if err := tmpl.ExecuteTemplate(c.Writer, name, data); err != nil {
 		// 发生错误
 		c.Fail(500, err.Error())
 	}
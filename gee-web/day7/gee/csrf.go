@@ -0,0 +1,223 @@
+// CSRF防护中间件
+// 默认使用double submit cookie: cookie里存放密钥, 表单或者请求头里带上由密钥生成的token
+// 也可以把密钥存放在session里(需要先使用Sessions中间件)
+package gee
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// 本次请求的CSRF密钥存放在上下文中的key
+const CSRFKey = "gee/csrf"
+
+// 表单字段名存放在上下文中的key
+const csrfFieldKey = "gee/csrf-field"
+
+// 密钥存放在session中的key
+const csrfSessionKey = "_csrf"
+
+const csrfSecretLen = 32
+
+type CSRFConfig struct {
+	// 使用session保存密钥, 默认使用cookie
+	UseSession bool
+	// 保存密钥的cookie名, 默认 _csrf
+	CookieName string
+	// cookie属性
+	CookiePath     string
+	CookieDomain   string
+	CookieSecure   bool
+	CookieSameSite http.SameSite
+	// 表单字段名, 默认 csrf_token
+	FieldName string
+	// AJAX请求使用的请求头, 默认 X-CSRF-Token
+	HeaderName string
+	// 这些路由组下的请求不做检查, 例如给第三方回调的接口
+	// 根路由组(没有前缀和host)会被忽略, 否则整个网站都不做检查
+	ExemptGroups []*RouterGroup
+	// 这些路径不做检查, 以 /* 结尾表示前缀匹配
+	ExemptPaths []string
+	// 校验失败时的处理函数, 默认返回403
+	ErrorHandler HandlerFunc
+}
+
+// CSRF中间件, GET/HEAD/OPTIONS/TRACE请求只生成密钥, 其他请求都要校验token
+// 在模板中使用 {{ csrfField .csrf }} 输出隐藏的表单字段, 其中 .csrf 来自 c.CSRFField()
+func CSRF(config CSRFConfig) HandlerFunc {
+	if config.CookieName == "" {
+		config.CookieName = "_csrf"
+	}
+	if config.CookiePath == "" {
+		config.CookiePath = "/"
+	}
+	if config.CookieSameSite == 0 {
+		config.CookieSameSite = http.SameSiteLaxMode
+	}
+	if config.FieldName == "" {
+		config.FieldName = "csrf_token"
+	}
+	if config.HeaderName == "" {
+		config.HeaderName = "X-CSRF-Token"
+	}
+	if config.ErrorHandler == nil {
+		config.ErrorHandler = func(c *Context) {
+			c.Fail(http.StatusForbidden, "invalid csrf token")
+		}
+	}
+	groups := config.ExemptGroups[:0:0]
+	for _, group := range config.ExemptGroups {
+		if group.prefix == "" && group.host == nil {
+			warnPrint("CSRF: exempting the root group would disable csrf protection, ignored")
+			continue
+		}
+		groups = append(groups, group)
+	}
+	config.ExemptGroups = groups
+	return func(c *Context) {
+		secret, err := loadCSRFSecret(c, &config)
+		if err != nil {
+			c.Fail(http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+		c.Set(CSRFKey, secret)
+		c.Set(csrfFieldKey, config.FieldName)
+
+		if isSafeMethod(c.Method) || config.exempt(c) {
+			c.Next()
+			return
+		}
+		token := c.Req.Header.Get(config.HeaderName)
+		if token == "" {
+			token = c.PostForm(config.FieldName)
+		}
+		if !validCSRFToken(token, secret) {
+			c.Abort()
+			config.ErrorHandler(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// 生成本次请求的CSRF token, 每次调用的结果都不同, 可以防止BREACH攻击
+func (c *Context) CSRFToken() string {
+	val, ok := c.Get(CSRFKey)
+	if !ok {
+		panic("gee: CSRFToken needs the CSRF middleware")
+	}
+	secret := val.([]byte)
+	// token = mask | (mask ^ secret)
+	mask := randomBytes(csrfSecretLen)
+	token := make([]byte, 2*csrfSecretLen)
+	copy(token, mask)
+	for i := range secret {
+		token[csrfSecretLen+i] = mask[i] ^ secret[i]
+	}
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+// 模板中的表单字段, 字段名使用CSRFConfig.FieldName
+type CSRFField struct {
+	Name  string
+	Token string
+}
+
+// 生成本次请求的表单字段, 交给模板函数 csrfField 输出
+//
+//	c.HTML(http.StatusOK, "form.tmpl", gee.H{"csrf": c.CSRFField()})
+func (c *Context) CSRFField() CSRFField {
+	token := c.CSRFToken()
+	return CSRFField{Name: c.GetString(csrfFieldKey), Token: token}
+}
+
+// 模板函数, 输出隐藏的表单字段
+func csrfField(field CSRFField) template.HTML {
+	return template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(field.Name) +
+		`" value="` + template.HTMLEscapeString(field.Token) + `">`)
+}
+
+// 读取密钥, 不存在时生成一个新的并保存
+func loadCSRFSecret(c *Context, config *CSRFConfig) ([]byte, error) {
+	if config.UseSession {
+		s := c.Session()
+		if secret, ok := s.Get(csrfSessionKey).([]byte); ok && len(secret) == csrfSecretLen {
+			return secret, nil
+		}
+		secret := randomBytes(csrfSecretLen)
+		s.Set(csrfSessionKey, secret)
+		return secret, s.Save()
+	}
+
+	if value, err := c.Cookie(config.CookieName); err == nil {
+		secret, err := base64.RawURLEncoding.DecodeString(value)
+		if err == nil && len(secret) == csrfSecretLen {
+			return secret, nil
+		}
+	}
+	secret := randomBytes(csrfSecretLen)
+	c.SetCookie(&http.Cookie{
+		Name:     config.CookieName,
+		Value:    base64.RawURLEncoding.EncodeToString(secret),
+		Path:     config.CookiePath,
+		Domain:   config.CookieDomain,
+		Secure:   config.CookieSecure,
+		HttpOnly: true,
+		SameSite: config.CookieSameSite,
+	})
+	return secret, nil
+}
+
+func validCSRFToken(token string, secret []byte) bool {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) != 2*csrfSecretLen {
+		return false
+	}
+	unmasked := make([]byte, csrfSecretLen)
+	for i := range unmasked {
+		unmasked[i] = raw[i] ^ raw[csrfSecretLen+i]
+	}
+	return subtle.ConstantTimeCompare(unmasked, secret) == 1
+}
+
+func (config *CSRFConfig) exempt(c *Context) bool {
+	path := c.Path
+	for _, group := range config.ExemptGroups {
+		if group.host != nil {
+			if _, ok := group.host.match(requestHost(c.Req.Host)); !ok {
+				continue
+			}
+		}
+		if hasPathPrefix(path, group.prefix) {
+			return true
+		}
+	}
+	for _, p := range config.ExemptPaths {
+		if strings.HasSuffix(p, "/*") && strings.HasPrefix(path, p[:len(p)-1]) {
+			return true
+		}
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// 前缀后面必须是 /, /hook 不能匹配 /hooks-admin
+func hasPathPrefix(path string, prefix string) bool {
+	if prefix == "" || strings.HasSuffix(prefix, "/") {
+		return strings.HasPrefix(path, prefix)
+	}
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCSRF(t *testing.T) {
+	r := New()
+	hook := r.Group("/hook")
+	r.Use(CSRF(CSRFConfig{ExemptGroups: []*RouterGroup{hook}}))
+	r.GET("/form", func(c *Context) {
+		c.String(http.StatusOK, "%s", c.CSRFToken())
+	})
+	r.POST("/form", func(c *Context) {
+		c.String(http.StatusOK, "saved")
+	})
+	hook.POST("/github", func(c *Context) {
+		c.String(http.StatusOK, "hooked")
+	})
+	r.POST("/hooks-admin/delete", func(c *Context) {
+		c.String(http.StatusOK, "deleted")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/form", nil))
+	cookie := w.Result().Cookies()[0]
+	token := w.Body.String()
+
+	post := func(path, token string) int {
+		form := url.Values{"csrf_token": {token}}
+		req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := post("/form", token); code != http.StatusOK {
+		t.Fatalf("valid token should pass, got %d", code)
+	}
+	if code := post("/form", "forged"); code != http.StatusForbidden {
+		t.Fatalf("forged token should be rejected, got %d", code)
+	}
+	if code := post("/hook/github", ""); code != http.StatusOK {
+		t.Fatalf("exempt group should pass, got %d", code)
+	}
+	if code := post("/hooks-admin/delete", ""); code != http.StatusForbidden {
+		t.Fatalf("a path sharing the group prefix without a / boundary should be checked, got %d", code)
+	}
+}
+
+func TestCSRFFieldName(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "form.tmpl"), []byte(`<form>{{ csrfField .csrf }}</form>`), 0644)
+
+	r := New()
+	r.LoadHTMLGlob(filepath.Join(dir, "*"))
+	r.Use(CSRF(CSRFConfig{FieldName: "_token", ExemptGroups: []*RouterGroup{r.RouterGroup}}))
+	r.GET("/form", func(c *Context) {
+		c.HTML(http.StatusOK, "form.tmpl", H{"csrf": c.CSRFField()})
+	})
+	r.POST("/form", func(c *Context) {
+		c.String(http.StatusOK, "saved")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/form", nil))
+	cookie := w.Result().Cookies()[0]
+	m := regexp.MustCompile(`name="([^"]+)" value="([^"]+)"`).FindStringSubmatch(w.Body.String())
+	if m == nil || m[1] != "_token" {
+		t.Fatalf("expect a hidden field named _token, got %q", w.Body.String())
+	}
+
+	form := url.Values{m[1]: {m[2]}}
+	req := httptest.NewRequest("POST", "/form", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("form rendered by csrfField should pass, got %d %s", w.Code, w.Body.String())
+	}
+
+	// 根路由组不能被豁免
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/form", nil))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("exempting the root group should be ignored, got %d", w.Code)
+	}
+}
@@ -0,0 +1,221 @@
+// 统一的错误处理: 业务函数通过 c.Error 记录错误, ErrorHandler 中间件在最后统一响应
+package gee
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type ErrorType uint64
+
+const (
+	// 请求参数绑定失败, 默认响应400
+	ErrorTypeBind ErrorType = 1 << iota
+	// 渲染响应失败
+	ErrorTypeRender
+	// 内部错误, 不会返回给客户端
+	ErrorTypePrivate
+	// 可以返回给客户端的错误
+	ErrorTypePublic
+
+	ErrorTypeAny ErrorType = 1<<64 - 1
+)
+
+// 记录在上下文中的错误
+type Error struct {
+	Err  error
+	Type ErrorType
+	// 响应的状态码, 为0时由ErrorHandler决定
+	Status int
+	// 附加信息, 会放在problem+json的meta字段里(只有公开的错误)
+	Meta interface{}
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) SetType(t ErrorType) *Error {
+	e.Type = t
+	return e
+}
+
+func (e *Error) SetStatus(code int) *Error {
+	e.Status = code
+	return e
+}
+
+func (e *Error) SetMeta(meta interface{}) *Error {
+	e.Meta = meta
+	return e
+}
+
+func (e *Error) IsType(t ErrorType) bool {
+	return e.Type&t > 0
+}
+
+// 是否可以把错误信息返回给客户端
+func (e *Error) isPublic() bool {
+	return e.IsType(ErrorTypePublic | ErrorTypeBind)
+}
+
+type errorMsgs []*Error
+
+// 按类型过滤
+func (a errorMsgs) ByType(t ErrorType) errorMsgs {
+	var result errorMsgs
+	for _, e := range a {
+		if e.IsType(t) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// 最后一个错误, 没有时返回nil
+func (a errorMsgs) Last() *Error {
+	if len(a) == 0 {
+		return nil
+	}
+	return a[len(a)-1]
+}
+
+func (a errorMsgs) String() string {
+	var b strings.Builder
+	for i, e := range a {
+		fmt.Fprintf(&b, "Error #%02d: %s\n", i+1, e.Err)
+	}
+	return b.String()
+}
+
+// 记录一个错误, 默认是内部错误, 不会中断后面的函数
+// 可以链式设置类型和状态码: c.Error(err).SetType(gee.ErrorTypePublic).SetStatus(404)
+func (c *Context) Error(err error) *Error {
+	if err == nil {
+		panic("gee: err is nil")
+	}
+	var parsed *Error
+	if !errors.As(err, &parsed) {
+		parsed = &Error{Err: err, Type: ErrorTypePrivate}
+	}
+	c.Errors = append(c.Errors, parsed)
+	return parsed
+}
+
+// RFC 7807 problem details
+type Problem struct {
+	Type     string      `json:"type"`
+	Title    string      `json:"title"`
+	Status   int         `json:"status"`
+	Detail   string      `json:"detail,omitempty"`
+	Instance string      `json:"instance,omitempty"`
+	Errors   []string    `json:"errors,omitempty"`
+	Meta     interface{} `json:"meta,omitempty"`
+}
+
+type ErrorHandlerConfig struct {
+	// 根据错误决定状态码, 默认: 设置了Status时使用Status, 绑定错误400, 其他500
+	StatusFunc func(err *Error) int
+	// 渲染HTML时使用的模板名, 模板的数据是 *Problem, 为空时使用内置页面
+	HTMLTemplate string
+	// problem的type字段, 例如 https://example.com/problems/, 后面会拼上状态码
+	TypeBaseURI string
+}
+
+// 错误处理中间件, 在后面的函数都执行完之后, 把 c.Errors 转换为统一的响应
+// 浏览器请求返回HTML页面, 其他请求返回 application/problem+json
+// 如果业务函数已经写了响应, 就只打印日志
+func ErrorHandler(config ErrorHandlerConfig) HandlerFunc {
+	if config.StatusFunc == nil {
+		config.StatusFunc = defaultErrorStatus
+	}
+	return func(c *Context) {
+		w := c.Writer
+		sw := &statusWriter{ResponseWriter: w}
+		c.Writer = sw
+		defer func() {
+			c.Writer = w
+		}()
+		c.Next()
+		if len(c.Errors) == 0 {
+			return
+		}
+		for _, e := range c.Errors.ByType(ErrorTypePrivate | ErrorTypeRender) {
+			log.Printf("[%s %s] %v", c.Method, c.Path, e.Err)
+		}
+		// 后面的函数已经写了响应, 不能再追加
+		if sw.written() {
+			return
+		}
+
+		last := c.Errors.Last()
+		status := config.StatusFunc(last)
+		problem := &Problem{
+			Type:     "about:blank",
+			Title:    http.StatusText(status),
+			Status:   status,
+			Instance: c.Req.URL.Path,
+		}
+		if config.TypeBaseURI != "" {
+			problem.Type = fmt.Sprintf("%s%d", config.TypeBaseURI, status)
+		}
+		if last.isPublic() {
+			problem.Detail = last.Error()
+			problem.Meta = last.Meta
+		}
+		for _, e := range c.Errors {
+			if e.isPublic() {
+				problem.Errors = append(problem.Errors, e.Error())
+			}
+		}
+
+		if acceptsHTML(c.Req) {
+			if config.HTMLTemplate != "" {
+				c.HTML(status, config.HTMLTemplate, problem)
+				return
+			}
+			c.SetHeader("Content-Type", "text/html; charset=utf-8")
+			c.Status(status)
+			errorPage.Execute(c.Writer, problem)
+			return
+		}
+		c.SetHeader("Content-Type", "application/problem+json")
+		c.Status(status)
+		json.NewEncoder(c.Writer).Encode(problem)
+	}
+}
+
+func defaultErrorStatus(err *Error) int {
+	if err.Status != 0 {
+		return err.Status
+	}
+	if err.IsType(ErrorTypeBind) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// 浏览器的Accept里text/html排在前面
+func acceptsHTML(req *http.Request) bool {
+	accept := req.Header.Get("Accept")
+	html := strings.Index(accept, "text/html")
+	if html < 0 {
+		return false
+	}
+	other := strings.Index(accept, "json")
+	return other < 0 || html < other
+}
+
+var errorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html><head><title>{{.Status}} {{.Title}}</title></head>
+<body><h1>{{.Status}} {{.Title}}</h1>{{with .Detail}}<p>{{.}}</p>{{end}}</body></html>
+`))
@@ -0,0 +1,56 @@
+package gee
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestErrorHandler(t *testing.T) {
+	r := New()
+	r.Use(ErrorHandler(ErrorHandlerConfig{}))
+	r.GET("/users/:id", func(c *Context) {
+		c.Error(errors.New("db: connection refused"))
+		c.Error(errors.New("user not found")).SetType(ErrorTypePublic).SetStatus(http.StatusNotFound)
+	})
+	r.GET("/internal", func(c *Context) {
+		c.Error(errors.New("db: connection refused"))
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/users/1", nil))
+	var p Problem
+	json.Unmarshal(w.Body.Bytes(), &p)
+	if w.Code != 404 || w.Header().Get("Content-Type") != "application/problem+json" ||
+		p.Detail != "user not found" || p.Instance != "/users/1" || len(p.Errors) != 1 {
+		t.Fatalf("unexpected problem: %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/internal", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	r.ServeHTTP(w, req)
+	if w.Code != 500 || !strings.Contains(w.Body.String(), "<h1>500 Internal Server Error</h1>") || strings.Contains(w.Body.String(), "db:") {
+		t.Fatalf("private error should be hidden: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestErrorHandlerAfterDirectWrite(t *testing.T) {
+	r := New()
+	r.Use(ErrorHandler(ErrorHandlerConfig{}))
+	r.GET("/wrapped", func(c *Context) {
+		WrapF(func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte("payload"))
+		})(c)
+		c.Error(errors.New("written before the error"))
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/wrapped", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "payload" {
+		t.Fatalf("expect the original response only, got %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,181 @@
+// 条件请求: ETag / Last-Modified, 资源没有变化时返回304, 前置条件不满足时返回412
+package gee
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type ETagConfig struct {
+	// 生成弱ETag(W/"..."), 响应内容语义相同但字节不同时(例如压缩)也能匹配
+	Weak bool
+}
+
+// 为GET/HEAD的响应自动生成强ETag
+func ETag() HandlerFunc {
+	return ETagWithConfig(ETagConfig{})
+}
+
+// 缓存后面函数写入的响应, 对200的响应根据内容计算ETag
+// If-None-Match 匹配或者 If-Modified-Since 之后没有修改时, 返回304并丢弃响应内容
+// 业务函数自己设置了ETag时不会覆盖; 流式响应(SSE等)不要使用这个中间件
+func ETagWithConfig(config ETagConfig) HandlerFunc {
+	return func(c *Context) {
+		if c.Method != http.MethodGet && c.Method != http.MethodHead {
+			c.Next()
+			return
+		}
+		w := c.Writer
+		bw := &bufferWriter{ResponseWriter: w}
+		c.Writer = bw
+		defer func() {
+			c.Writer = w
+		}()
+		c.Next()
+
+		// 后面的函数没有写入任何内容
+		if bw.code == 0 {
+			return
+		}
+		if bw.code == http.StatusOK {
+			if w.Header().Get("ETag") == "" {
+				w.Header().Set("ETag", GenerateETag(bw.buf.Bytes(), config.Weak))
+			}
+			if notModified(c.Req, w.Header().Get("ETag"), w.Header().Get("Last-Modified")) {
+				writeNotModified(w)
+				return
+			}
+		}
+		w.WriteHeader(bw.code)
+		w.Write(bw.buf.Bytes())
+	}
+}
+
+// 根据内容生成ETag, 带引号, 例如 "q1Zr..." 或 W/"q1Zr..."
+func GenerateETag(data []byte, weak bool) string {
+	sum := sha256.Sum256(data)
+	tag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+	if weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+// 设置ETag和Last-Modified, 客户端的缓存仍然有效时返回304并终止, 返回true
+// etag为空或lastModified为零值时不使用对应的条件
+//
+//	if c.NotModified(article.ETag, article.UpdatedAt) {
+//		return
+//	}
+func (c *Context) NotModified(etag string, lastModified time.Time) bool {
+	if etag != "" {
+		c.SetHeader("ETag", etag)
+	}
+	if !lastModified.IsZero() {
+		c.SetHeader("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	if c.Method != http.MethodGet && c.Method != http.MethodHead {
+		return false
+	}
+	if !notModified(c.Req, c.Writer.Header().Get("ETag"), c.Writer.Header().Get("Last-Modified")) {
+		return false
+	}
+	c.Abort()
+	c.StatusCode = http.StatusNotModified
+	writeNotModified(c.Writer)
+	return true
+}
+
+// 检查If-Match和If-Unmodified-Since, 用于PUT/PATCH/DELETE防止覆盖别人的修改
+// 资源当前的版本不满足条件时返回412并终止, 返回false
+//
+//	if !c.CheckPrecondition(article.ETag, article.UpdatedAt) {
+//		return
+//	}
+func (c *Context) CheckPrecondition(etag string, lastModified time.Time) bool {
+	ok := true
+	if ifMatch := c.Req.Header.Get("If-Match"); ifMatch != "" {
+		ok = etag != "" && etagMatch(ifMatch, etag, false)
+	} else if since, err := http.ParseTime(c.Req.Header.Get("If-Unmodified-Since")); err == nil && !lastModified.IsZero() {
+		ok = !lastModified.Truncate(time.Second).After(since)
+	}
+	if !ok {
+		c.Fail(http.StatusPreconditionFailed, http.StatusText(http.StatusPreconditionFailed))
+	}
+	return ok
+}
+
+// GET/HEAD请求的缓存是否仍然有效
+// 有If-None-Match时忽略If-Modified-Since
+func notModified(req *http.Request, etag string, lastModified string) bool {
+	if ifNoneMatch := req.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return etag != "" && etagMatch(ifNoneMatch, etag, true)
+	}
+	since, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+	return !modified.After(since)
+}
+
+// header是逗号分隔的ETag列表或者 *
+// 弱比较忽略W/前缀, 强比较时弱ETag不匹配任何值
+func etagMatch(header string, etag string, weak bool) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	if !weak && strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = candidate[2:]
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// 304不能带有响应体, 去掉描述响应体的头
+func writeNotModified(w http.ResponseWriter) {
+	h := w.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	h.Del("Content-Encoding")
+	w.WriteHeader(http.StatusNotModified)
+}
+
+// 缓存响应内容, 响应头直接写到原来的writer里
+type bufferWriter struct {
+	http.ResponseWriter
+	buf  bytes.Buffer
+	code int
+}
+
+func (bw *bufferWriter) Write(p []byte) (int, error) {
+	if bw.code == 0 {
+		bw.code = http.StatusOK
+	}
+	return bw.buf.Write(p)
+}
+
+func (bw *bufferWriter) WriteHeader(code int) {
+	if bw.code == 0 {
+		bw.code = code
+	}
+}
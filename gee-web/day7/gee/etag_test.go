@@ -0,0 +1,93 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestETagMiddleware(t *testing.T) {
+	r := New()
+	r.Use(ETag())
+	r.GET("/data", func(c *Context) {
+		c.JSON(http.StatusOK, H{"name": "geektutu"})
+	})
+	r.GET("/missing", func(c *Context) {
+		c.String(http.StatusNotFound, "not found")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/data", nil))
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" || w.Body.String() == "" {
+		t.Fatalf("expect 200 with etag, got %d %q", w.Code, etag)
+	}
+
+	req := httptest.NewRequest("GET", "/data", nil)
+	req.Header.Set("If-None-Match", `"other", W/`+etag)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 || w.Header().Get("Content-Type") != "" {
+		t.Fatalf("expect 304 without body, got %d %q", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/missing", nil)
+	req.Header.Set("If-None-Match", "*")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound || w.Header().Get("ETag") != "" {
+		t.Fatalf("expect 404 without etag, got %d", w.Code)
+	}
+
+	if tag := GenerateETag([]byte("a"), true); tag[:3] != `W/"` {
+		t.Fatalf("expect weak etag, got %s", tag)
+	}
+}
+
+func TestConditionalHelpers(t *testing.T) {
+	updated := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	r := New()
+	r.GET("/article", func(c *Context) {
+		if c.NotModified(`"v2"`, updated) {
+			return
+		}
+		c.String(http.StatusOK, "article")
+	})
+	r.Handle("PUT", "/article", func(c *Context) {
+		if !c.CheckPrecondition(`"v2"`, updated) {
+			return
+		}
+		c.String(http.StatusOK, "saved")
+	})
+
+	tests := []struct {
+		method string
+		header map[string]string
+		code   int
+	}{
+		{"GET", nil, http.StatusOK},
+		{"GET", map[string]string{"If-None-Match": `"v1"`}, http.StatusOK},
+		{"GET", map[string]string{"If-None-Match": `"v2"`}, http.StatusNotModified},
+		{"GET", map[string]string{"If-Modified-Since": updated.Format(http.TimeFormat)}, http.StatusNotModified},
+		{"GET", map[string]string{"If-Modified-Since": updated.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusOK},
+		// 有If-None-Match时忽略If-Modified-Since
+		{"GET", map[string]string{"If-None-Match": `"v1"`, "If-Modified-Since": updated.Format(http.TimeFormat)}, http.StatusOK},
+		{"PUT", nil, http.StatusOK},
+		{"PUT", map[string]string{"If-Match": `"v2"`}, http.StatusOK},
+		{"PUT", map[string]string{"If-Match": `"v1"`}, http.StatusPreconditionFailed},
+		{"PUT", map[string]string{"If-Match": `W/"v2"`}, http.StatusPreconditionFailed},
+		{"PUT", map[string]string{"If-Unmodified-Since": updated.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusPreconditionFailed},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/article", nil)
+		for k, v := range tt.header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.code {
+			t.Errorf("case %d: expect %d, got %d", i, tt.code, w.Code)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// 文件下载, 支持Range请求和条件请求
+package gee
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// 返回本地文件, Range / If-Modified-Since 等由http.ServeContent处理
+// 文件不存在或者是目录时返回404
+func (c *Context) File(filePath string) {
+	c.FileFromFS(filepath.Base(filePath), http.Dir(filepath.Dir(filePath)))
+}
+
+// 以附件形式返回文件, 浏览器会下载并保存为filename, filename可以包含中文
+func (c *Context) FileAttachment(filePath string, filename string) {
+	c.SetHeader("Content-Disposition", contentDisposition("attachment", filename))
+	c.File(filePath)
+}
+
+// 从fs中返回文件, 可以配合 http.FS 使用go:embed的文件
+func (c *Context) FileFromFS(name string, fs http.FileSystem) {
+	f, info, err := openStatic(fs, path.Clean("/"+name))
+	if err == nil && info.IsDir() {
+		f.Close()
+		err = errIsDir
+	}
+	if err != nil {
+		c.String(http.StatusNotFound, "404 NOT FOUND: %s\n", c.Path)
+		return
+	}
+	defer f.Close()
+	http.ServeContent(c.Writer, c.Req, info.Name(), info.ModTime(), f)
+}
+
+// 从reader中读取并响应, 不需要把内容全部读到内存里
+// contentLength小于0时不设置Content-Length
+// code为200并且reader实现了io.ReadSeeker时(例如 *os.File, *bytes.Reader), 支持Range请求
+func (c *Context) DataFromReader(code int, contentLength int64, contentType string, reader io.Reader, extraHeaders map[string]string) {
+	if contentType != "" {
+		c.SetHeader("Content-Type", contentType)
+	}
+	for k, v := range extraHeaders {
+		c.SetHeader(k, v)
+	}
+	if rs, ok := reader.(io.ReadSeeker); ok && code == http.StatusOK {
+		http.ServeContent(c.Writer, c.Req, "", time.Time{}, rs)
+		return
+	}
+	if contentLength >= 0 {
+		c.SetHeader("Content-Length", strconv.FormatInt(contentLength, 10))
+	}
+	c.Status(code)
+	io.Copy(c.Writer, reader)
+}
+
+var errIsDir = errors.New("gee: is a directory")
+
+// 生成Content-Disposition, 非ASCII的文件名使用RFC 5987编码
+func contentDisposition(disposition string, filename string) string {
+	if filename == "" {
+		return disposition
+	}
+	return mime.FormatMediaType(disposition, map[string]string{"filename": filename})
+}
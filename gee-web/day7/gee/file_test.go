@@ -0,0 +1,89 @@
+package gee
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "report.txt")
+	os.WriteFile(file, []byte("0123456789"), 0644)
+
+	r := New()
+	r.GET("/file", func(c *Context) {
+		c.File(file)
+	})
+	r.GET("/dir", func(c *Context) {
+		c.File(dir)
+	})
+	r.GET("/download", func(c *Context) {
+		c.FileAttachment(file, "报告.txt")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/file", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "0123456789" || w.Header().Get("Last-Modified") == "" {
+		t.Fatalf("expect whole file, got %d %q", w.Code, w.Body.String())
+	}
+
+	req := httptest.NewRequest("GET", "/file", nil)
+	req.Header.Set("Range", "bytes=2-4")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusPartialContent || w.Body.String() != "234" || w.Header().Get("Content-Range") != "bytes 2-4/10" {
+		t.Fatalf("expect range, got %d %q", w.Code, w.Body.String())
+	}
+
+	req.Header.Set("Range", "bytes=0-1,8-")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusPartialContent || !strings.HasPrefix(w.Header().Get("Content-Type"), "multipart/byteranges") {
+		t.Fatalf("expect multipart range, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/dir", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expect 404 for directory, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/download", nil))
+	_, params, err := mime.ParseMediaType(w.Header().Get("Content-Disposition"))
+	if err != nil || params["filename"] != "报告.txt" || w.Body.String() != "0123456789" {
+		t.Fatalf("unexpected attachment: %q %v", w.Header().Get("Content-Disposition"), err)
+	}
+}
+
+func TestDataFromReader(t *testing.T) {
+	r := New()
+	r.GET("/stream", func(c *Context) {
+		reader := io.MultiReader(strings.NewReader("hello "), strings.NewReader("gee"))
+		c.DataFromReader(http.StatusOK, 9, "text/plain", reader, map[string]string{"X-Source": "stream"})
+	})
+	r.GET("/seek", func(c *Context) {
+		c.DataFromReader(http.StatusOK, -1, "text/plain", bytes.NewReader([]byte("hello gee")), nil)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/stream", nil))
+	if w.Body.String() != "hello gee" || w.Header().Get("Content-Length") != "9" || w.Header().Get("X-Source") != "stream" {
+		t.Fatalf("unexpected stream response: %q %v", w.Body.String(), w.Header())
+	}
+
+	req := httptest.NewRequest("GET", "/seek", nil)
+	req.Header.Set("Range", "bytes=6-")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusPartialContent || w.Body.String() != "gee" || w.Header().Get("Content-Type") != "text/plain" {
+		t.Fatalf("expect range from seeker, got %d %q", w.Code, w.Body.String())
+	}
+}
@@ -1,10 +1,15 @@
 package gee
 
 import (
+	"context"
 	"html/template"
+	"net"
 	"net/http"
-	"path"
+	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
 )
 
 // 定义通用的key
@@ -23,6 +28,7 @@ type (
 		midddlewares []HandlerFunc // 中间件
 		parent *RouterGroup // 分组
 		engine *Engine // 共用一个engine
+		host *hostPattern // 只匹配这个host, 为nil时匹配所有host
 	}
 
 	Engine struct {
@@ -30,7 +36,24 @@ type (
 		router *router // 对应的路由
 		groups []*RouterGroup // 记录这个Engine的所有子路由组， 创建路由时将新的路由添加进去
 		htmlTemplates *template.Template
+		htmlPattern string // LoadHTMLGlob的参数, debug模式下用来重新加载
 		funcMap template.FuncMap
+
+		// 服务器配置, 在Run之前设置
+		Server ServerConfig
+		// 正在运行的服务器, 关闭时需要全部优雅退出
+		servers []*runningServer
+		serversMu sync.Mutex
+		onStart []func()
+		onShutdown []func()
+
+		// 可信代理, 通过SetTrustedProxies设置
+		trustedProxies []*net.IPNet
+		// 请求来自可信代理时, ClientIP按顺序查找的请求头, 默认 X-Forwarded-For, X-Real-IP
+		// 代理不会清除客户端发来的同名请求头时, 不要加入这个头
+		RemoteIPHeaders []string
+		// 注册过的所有路由, 用来生成文档
+		routes []*Route
 	}
 )
 
@@ -38,14 +61,47 @@ type (
 // 相当于构造函数
 func New() *Engine {
 
-	engine := &Engine {router: newRouter()}
+	engine := &Engine {router: newRouter(), Server: DefaultServerConfig()}
+	engine.RemoteIPHeaders = append([]string(nil), defaultRemoteIPHeaders...)
 	engine.RouterGroup = &RouterGroup{engine: engine}
 	engine.groups = []*RouterGroup{engine.RouterGroup}
+	debugPrint("Running in debug mode, switch to release mode in production with %s=%s", EnvGeeMode, ReleaseMode)
 	return engine
 }
 
-func (e *Engine) setFuncMap(funcMap *template.FuncMap) {
-	e.funcMap = *funcMap
+// 设置模板函数, 需要在LoadHTMLGlob之前调用
+func (e *Engine) SetFuncMap(funcMap template.FuncMap) {
+	e.funcMap = funcMap
+}
+
+// 加载模板, 除了自定义的函数外还内置了 csrfField
+// debug模式下每次渲染都会重新加载, 修改模板后不需要重启
+func (e *Engine) LoadHTMLGlob(pattern string) {
+	e.htmlPattern = pattern
+	e.htmlTemplates = template.Must(e.parseHTMLGlob(pattern))
+}
+
+func (e *Engine) parseHTMLGlob(pattern string) (*template.Template, error) {
+	funcMap := template.FuncMap{"csrfField": csrfField}
+	for name, fn := range e.funcMap {
+		funcMap[name] = fn
+	}
+	return template.New("").Funcs(funcMap).ParseGlob(pattern)
+}
+
+// 渲染时使用的模板, 没有加载过模板时返回nil
+func (e *Engine) templates() *template.Template {
+	if e == nil { // 不属于任何engine的上下文, 例如 WrapHandler
+		return nil
+	}
+	if IsDebugging() && e.htmlPattern != "" {
+		tmpl, err := e.parseHTMLGlob(e.htmlPattern)
+		if err == nil {
+			return tmpl
+		}
+		debugPrint("reload templates failed: %v", err)
+	}
+	return e.htmlTemplates
 }
 
 // 创建新的分组
@@ -59,6 +115,7 @@ func (rg *RouterGroup) Group(prefix string) *RouterGroup {
 		prefix: prefixGroup,
 		parent: rg,
 		engine: engine,
+		host: rg.host,
 	}
 	engine.groups = append(engine.groups, newGroup)
 	return newGroup
@@ -72,36 +129,92 @@ func (rg *RouterGroup) Use (handlers ...HandlerFunc) {
 
 // 由于是分组路由
 // 所以传递过来的其实是一个子路径， 在添加路由的时候要实现拼接
-func (rg *RouterGroup) addRouter(method string, comp string, handler HandlerFunc) {
+// 返回的Route可以用来添加文档信息, 见 route.go
+func (rg *RouterGroup) addRouter(method string, comp string, handler HandlerFunc) *Route {
 	pattern := rg.prefix + comp
+	route := &Route{Method: method, Path: pattern}
+	rg.engine.routes = append(rg.engine.routes, route)
 	// 添加在路由树里面
+	if rg.host != nil {
+		route.Host = rg.host.pattern
+		rg.engine.router.addHostRoute(rg.host, method, pattern, handler)
+		return route
+	}
 	rg.engine.router.addRoute(method, pattern, handler)
+	return route
 }
 
 // 实现GET
-func (rg *RouterGroup) GET(pattern string, handler HandlerFunc) {
-	rg.addRouter("GET", pattern, handler)
+func (rg *RouterGroup) GET(pattern string, handler HandlerFunc) *Route {
+	return rg.addRouter("GET", pattern, handler)
 }
 
 // 实现POST
-func (rg *RouterGroup) POST(pattern string, handler HandlerFunc) {
-	rg.addRouter("POST", pattern, handler)
+func (rg *RouterGroup) POST(pattern string, handler HandlerFunc) *Route {
+	return rg.addRouter("POST", pattern, handler)
+}
+
+// 注册任意请求方式的路由
+func (rg *RouterGroup) Handle(method string, pattern string, handler HandlerFunc) *Route {
+	return rg.addRouter(method, pattern, handler)
 }
 
 // 实现Run
 // 这个RUN方法独属于Engine
+// 收到SIGINT/SIGTERM后会等待正在处理的请求结束再退出
 func (engine *Engine) Run(addr string) (err error) {
-	return http.ListenAndServe(addr, engine)
+	ctx, stop := signalContext()
+	defer stop()
+	return engine.RunWithContext(ctx, addr)
+}
+
+// 收到SIGINT/SIGTERM后结束的ctx
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 }
 
 // 实现ServeHTTP接口
 // 实现这个接口后将会拦截所有的请求， 所以可以将请求逻辑全部放在这里来写
 func (engine *Engine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	c := engine.CreateContext(w, req)
+	engine.handleHTTPRequest(c)
+}
+
+// 在已经修改过路径的上下文上重新匹配路由并执行, 不需要再发起一次HTTP请求
+// 用于URL重写之类的中间件, 上下文里的Keys和Errors会保留
+// 调用之后新的路由已经处理完请求, 原来的调用链会被终止, 当前函数应该直接返回
+//
+//	r.GET("/old/:id", func(c *gee.Context) {
+//		c.Req.URL.Path = "/new/" + c.Param("id")
+//		r.HandleContext(c)
+//	})
+func (engine *Engine) HandleContext(c *Context) {
+	// 外层的Next还在遍历原来的handlers, 执行完之后要恢复, 否则新的调用链更短时会越界
+	oldIndex, oldHandlers := c.index, c.handlers
+	c.Path = c.Req.URL.Path
+	c.Method = c.Req.Method
+	c.Params = make(map[string]string)
+	c.index = -1
+	c.engine = engine
+	engine.handleHTTPRequest(c)
+	c.index, c.handlers = oldIndex, oldHandlers
+	c.Abort()
+}
+
+// 收集中间件, 匹配路由并执行
+func (engine *Engine) handleHTTPRequest(c *Context) {
+	req := c.Req
 	// 还需要实现中间件
 	// 当用户的请求来到时， 要先做中间件处理
 	midddlewares := make([]HandlerFunc, 0)
+	// 先确定使用哪个host的路由
+	hr := engine.router.matchHost(req.Host, req.Method, req.URL.Path)
 	// 先找到对应的路径
 	for _, group := range engine.groups {
+		if group.host != nil && (hr == nil || hr.host.pattern != group.host.pattern) {
+			// 指定了host的路由组, 只有请求使用了这个host的路由时才使用它的中间件
+			continue
+		}
 		if strings.HasPrefix(req.URL.Path, group.prefix) { // 用户请求的前缀和当前路由组前缀相等， 那么这个路由组的中间件就是应用在用户请求的中间件
 			// 将这个路由组的所有中间件保存
 			// 例如，假设我注册了一个路由 "/zxp", 并给这个路由指定了中间件方法
@@ -111,12 +224,16 @@ func (engine *Engine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		}
 	}
 
-
-	c := NewContext(w, req)
 	// 将这个路由组需要执行的中间件函数存在上下文中
 	c.handlers = midddlewares 
+	engine.router.handle(c, hr)
+}
+
+// 创建一个使用这个engine的上下文, 可以渲染模板, 主要用于测试
+func (engine *Engine) CreateContext(w http.ResponseWriter, req *http.Request) *Context {
+	c := NewContext(w, req)
 	c.engine = engine
-	engine.router.handle(c)
+	return c
 }
 
 /**
@@ -140,32 +257,8 @@ r.Static("/assets", "/usr/geektutu/blog/static")
 **/
 
 // day6 模板渲染
-// 将静态资源映射返回
-// fs是服务器资源的根路径
-func (rg *RouterGroup) createStaticHandler(relativePath string, fs http.FileSystem) HandlerFunc {
-	// 获取绝对路径
-	absolutePath := path.Join(rg.prefix, relativePath)
-	// 去掉前缀，并且将去掉前缀后的文件地址给http.FileServer
-	// 这个http.FileServer会去创建一个
-	fileServer := http.StripPrefix(absolutePath, http.FileServer(fs))
-
-	return func(c *Context) {
-		file := c.Params["filepath"]
-		// open, 如果不存在就会open失败
-		if _, err := fs.Open(file); err != nil { // open失败
-			c.Status(http.StatusNotFound)
-			return
-		}
-		fileServer.ServeHTTP(c.Writer, c.Req)
-	}
-}
-
+// 将静态资源映射返回, 具体实现在 static.go
 func (rg *RouterGroup) Static(relativePath string, root string) {
-	// 业务逻辑
-	handler := rg.createStaticHandler(relativePath, http.Dir(root))
 	// urlPath = /assets/*filepath
-	urlPattern := path.Join(relativePath, "/*filepath")
-	// Register GET handlers
-	// 添加路由
-	rg.GET(urlPattern, handler)
-}
\ No newline at end of file
+	rg.StaticWithConfig(relativePath, http.Dir(root), DefaultStaticConfig())
+}
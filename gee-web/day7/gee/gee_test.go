@@ -4,8 +4,15 @@ import (
 	"testing"
 	"reflect"
 	"fmt"
+	"os"
 )
 
+func TestMain(m *testing.M) {
+	// 测试时不打印路由信息
+	SetMode(TestMode)
+	os.Exit(m.Run())
+}
+
 func newTestRouter() *router {
 	r := newRouter()
 	r.addRoute("GET", "/", nil)
@@ -0,0 +1,220 @@
+// 测试工具: 不需要启动网络服务就可以测试gee的路由和中间件
+package geetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gee"
+)
+
+// 创建一个使用新Engine的上下文, 请求为 GET /
+// 用来直接测试单个HandlerFunc
+func CreateTestContext(w http.ResponseWriter) (*gee.Context, *gee.Engine) {
+	engine := gee.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	return engine.CreateContext(w, req), engine
+}
+
+// 在内存中对Engine发起请求的客户端, 会像浏览器一样保存cookie
+type Client struct {
+	t       testing.TB
+	handler http.Handler
+	header  http.Header
+	cookies map[string]*http.Cookie
+}
+
+func NewClient(t testing.TB, engine *gee.Engine) *Client {
+	return &Client{t: t, handler: engine, header: make(http.Header), cookies: make(map[string]*http.Cookie)}
+}
+
+// 设置之后每个请求都会带上的请求头
+func (cl *Client) SetHeader(key, value string) *Client {
+	cl.header.Set(key, value)
+	return cl
+}
+
+func (cl *Client) GET(path string) *Request {
+	return cl.Request(http.MethodGet, path)
+}
+
+func (cl *Client) POST(path string) *Request {
+	return cl.Request(http.MethodPost, path)
+}
+
+func (cl *Client) PUT(path string) *Request {
+	return cl.Request(http.MethodPut, path)
+}
+
+func (cl *Client) DELETE(path string) *Request {
+	return cl.Request(http.MethodDelete, path)
+}
+
+func (cl *Client) Request(method, path string) *Request {
+	return &Request{client: cl, method: method, path: path, header: cl.header.Clone()}
+}
+
+// 一个待发送的请求
+type Request struct {
+	client  *Client
+	method  string
+	path    string
+	header  http.Header
+	body    io.Reader
+	cookies []*http.Cookie
+}
+
+func (r *Request) Header(key, value string) *Request {
+	r.header.Set(key, value)
+	return r
+}
+
+func (r *Request) Cookie(cookie *http.Cookie) *Request {
+	r.cookies = append(r.cookies, cookie)
+	return r
+}
+
+// 设置query参数
+func (r *Request) Query(values url.Values) *Request {
+	sep := "?"
+	if strings.Contains(r.path, "?") {
+		sep = "&"
+	}
+	r.path += sep + values.Encode()
+	return r
+}
+
+// 以 application/x-www-form-urlencoded 提交表单
+func (r *Request) Form(values url.Values) *Request {
+	r.body = strings.NewReader(values.Encode())
+	r.header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+// 以JSON格式提交
+func (r *Request) JSON(v interface{}) *Request {
+	data, err := json.Marshal(v)
+	if err != nil {
+		r.client.t.Fatalf("geetest: encode json body: %v", err)
+	}
+	r.body = bytes.NewReader(data)
+	r.header.Set("Content-Type", "application/json")
+	return r
+}
+
+func (r *Request) Body(body io.Reader) *Request {
+	r.body = body
+	return r
+}
+
+// 发送请求, 通过Engine.ServeHTTP直接处理
+func (r *Request) Do() *Response {
+	cl := r.client
+	req := httptest.NewRequest(r.method, r.path, r.body)
+	req.Header = r.header
+	for _, c := range cl.cookies {
+		req.AddCookie(c)
+	}
+	for _, c := range r.cookies {
+		req.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	cl.handler.ServeHTTP(w, req)
+
+	resp := &Response{ResponseRecorder: w, t: cl.t}
+	for _, c := range w.Result().Cookies() {
+		if c.MaxAge < 0 || c.Value == "" {
+			delete(cl.cookies, c.Name)
+		} else {
+			cl.cookies[c.Name] = c
+		}
+	}
+	return resp
+}
+
+// 请求的响应, 带有一些断言函数, 断言失败时调用t.Fatalf
+type Response struct {
+	*httptest.ResponseRecorder
+	t testing.TB
+}
+
+func (r *Response) ExpectStatus(code int) *Response {
+	r.t.Helper()
+	if r.Code != code {
+		r.t.Fatalf("geetest: expect status %d, got %d, body: %s", code, r.Code, r.Body.String())
+	}
+	return r
+}
+
+func (r *Response) ExpectHeader(key, value string) *Response {
+	r.t.Helper()
+	if got := r.Header().Get(key); got != value {
+		r.t.Fatalf("geetest: expect header %s=%q, got %q", key, value, got)
+	}
+	return r
+}
+
+func (r *Response) ExpectBody(body string) *Response {
+	r.t.Helper()
+	if got := r.Body.String(); got != body {
+		r.t.Fatalf("geetest: expect body %q, got %q", body, got)
+	}
+	return r
+}
+
+func (r *Response) ExpectBodyContains(sub string) *Response {
+	r.t.Helper()
+	if !strings.Contains(r.Body.String(), sub) {
+		r.t.Fatalf("geetest: expect body to contain %q, got %q", sub, r.Body.String())
+	}
+	return r
+}
+
+// 把响应和expected都转换为通用的JSON结构后比较, 不受字段顺序影响
+func (r *Response) ExpectJSON(expected interface{}) *Response {
+	r.t.Helper()
+	var got, want interface{}
+	if err := json.Unmarshal(r.Body.Bytes(), &got); err != nil {
+		r.t.Fatalf("geetest: response is not json: %v, body: %s", err, r.Body.String())
+	}
+	data, err := json.Marshal(expected)
+	if err != nil {
+		r.t.Fatalf("geetest: encode expected json: %v", err)
+	}
+	json.Unmarshal(data, &want)
+	if !reflect.DeepEqual(got, want) {
+		r.t.Fatalf("geetest: expect json %s, got %s", data, r.Body.String())
+	}
+	return r
+}
+
+// 断言响应设置了某个cookie
+func (r *Response) ExpectCookie(name, value string) *Response {
+	r.t.Helper()
+	for _, c := range r.Result().Cookies() {
+		if c.Name == name {
+			if c.Value != value {
+				r.t.Fatalf("geetest: expect cookie %s=%q, got %q", name, value, c.Value)
+			}
+			return r
+		}
+	}
+	r.t.Fatalf("geetest: cookie %s not set", name)
+	return r
+}
+
+// 把响应解析到v中
+func (r *Response) DecodeJSON(v interface{}) *Response {
+	r.t.Helper()
+	if err := json.Unmarshal(r.Body.Bytes(), v); err != nil {
+		r.t.Fatalf("geetest: decode json: %v, body: %s", err, r.Body.String())
+	}
+	return r
+}
@@ -0,0 +1,46 @@
+package geetest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"gee"
+)
+
+func TestClient(t *testing.T) {
+	r := gee.New()
+	r.POST("/login", func(c *gee.Context) {
+		c.SetCookie(&http.Cookie{Name: "user", Value: c.PostForm("username")})
+		c.JSON(http.StatusOK, gee.H{"username": c.PostForm("username"), "ok": true})
+	})
+	r.GET("/me", func(c *gee.Context) {
+		user, err := c.Cookie("user")
+		if err != nil {
+			c.Fail(http.StatusUnauthorized, "login first")
+			return
+		}
+		c.SetHeader("X-User", user)
+		c.String(http.StatusOK, "hello %s", user)
+	})
+
+	client := NewClient(t, r)
+	client.GET("/me").Do().ExpectStatus(http.StatusUnauthorized).ExpectJSON(gee.H{"msg": "login first"})
+	client.POST("/login").Form(url.Values{"username": {"geektutu"}}).Do().
+		ExpectStatus(http.StatusOK).
+		ExpectCookie("user", "geektutu").
+		ExpectJSON(map[string]interface{}{"ok": true, "username": "geektutu"})
+	// cookie会被保存下来
+	client.GET("/me").Do().ExpectStatus(http.StatusOK).ExpectHeader("X-User", "geektutu").ExpectBody("hello geektutu")
+}
+
+func TestCreateTestContext(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := CreateTestContext(w)
+	c.Params["name"] = "geektutu"
+	c.String(http.StatusCreated, "hello %s", c.Param("name"))
+	if w.Code != http.StatusCreated || w.Body.String() != "hello geektutu" {
+		t.Fatalf("unexpected response: %d %q", w.Code, w.Body.String())
+	}
+}
@@ -1 +1,3 @@
-module gee
\ No newline at end of file
+module gee
+
+go 1.25
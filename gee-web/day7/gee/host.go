@@ -0,0 +1,74 @@
+// 基于Host的路由, 例如 api.example.com 和 {tenant}.example.com
+package gee
+
+import (
+	"net"
+	"strings"
+)
+
+// 解析后的host规则, 按 . 切分, {name} 匹配任意一段并作为参数
+type hostPattern struct {
+	pattern string
+	labels  []string
+	// 是否含有参数
+	wildcard bool
+}
+
+func parseHostPattern(pattern string) *hostPattern {
+	hp := &hostPattern{pattern: strings.ToLower(pattern), labels: strings.Split(strings.ToLower(pattern), ".")}
+	for _, label := range hp.labels {
+		if label == "" {
+			panic("gee: invalid host pattern " + pattern)
+		}
+		if isHostParam(label) {
+			hp.wildcard = true
+		}
+	}
+	return hp
+}
+
+func isHostParam(label string) bool {
+	return len(label) > 2 && label[0] == '{' && label[len(label)-1] == '}'
+}
+
+// 匹配成功时返回host中的参数
+func (hp *hostPattern) match(host string) (map[string]string, bool) {
+	labels := strings.Split(host, ".")
+	if len(labels) != len(hp.labels) {
+		return nil, false
+	}
+	var params map[string]string
+	for i, label := range hp.labels {
+		if isHostParam(label) {
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[label[1:len(label)-1]] = labels[i]
+		} else if label != labels[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// 创建一个只匹配指定host的路由组, 前缀和中间件与当前路由组相同
+// 例如 r.Host("{tenant}.example.com"), host中的参数可以通过 c.Param("tenant") 获取
+// 完全匹配的host优先于带参数的host, 都不匹配时使用没有指定host的路由
+func (rg *RouterGroup) Host(pattern string) *RouterGroup {
+	newGroup := &RouterGroup{
+		prefix: rg.prefix,
+		parent: rg,
+		engine: rg.engine,
+		host:   parseHostPattern(pattern),
+	}
+	rg.engine.groups = append(rg.engine.groups, newGroup)
+	return newGroup
+}
+
+// 去掉端口, 转换为小写
+func requestHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
@@ -0,0 +1,50 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHostRouting(t *testing.T) {
+	r := New()
+	r.GET("/", func(c *Context) {
+		c.String(http.StatusOK, "default")
+	})
+	tenant := r.Host("{tenant}.example.com")
+	tenant.Use(func(c *Context) {
+		c.SetHeader("X-Tenant", "tenant:"+c.Param("tenant"))
+	})
+	tenant.GET("/", func(c *Context) {
+		c.String(http.StatusOK, "tenant %s", c.Param("tenant"))
+	})
+	tenant.Group("/users").GET("/:id", func(c *Context) {
+		c.String(http.StatusOK, "%s user %s", c.Param("tenant"), c.Param("id"))
+	})
+	r.Host("api.example.com").GET("/", func(c *Context) {
+		c.String(http.StatusOK, "api")
+	})
+
+	tests := []struct {
+		host, path, body, tenant string
+	}{
+		{"api.example.com", "/", "api", ""},
+		{"API.example.com:8080", "/", "api", ""},
+		{"foo.example.com", "/", "tenant foo", "tenant:foo"},
+		{"foo.example.com", "/users/1", "foo user 1", "tenant:foo"},
+		{"example.com", "/", "default", ""},
+		{"a.b.example.com", "/", "default", ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", tt.path, nil)
+		req.Host = tt.host
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK || w.Body.String() != tt.body {
+			t.Fatalf("%s%s: expect %q, got %d %q", tt.host, tt.path, tt.body, w.Code, w.Body.String())
+		}
+		if got := w.Header().Get("X-Tenant"); got != tt.tenant {
+			t.Fatalf("%s%s: expect tenant middleware %q, got %q", tt.host, tt.path, tt.tenant, got)
+		}
+	}
+}
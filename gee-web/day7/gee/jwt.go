@@ -0,0 +1,471 @@
+// JWT校验中间件, 只依赖标准库的crypto包
+// 支持 HS256 / RS256 / ES256, 以及通过JWKS文件轮换密钥
+package gee
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 校验通过后, claims存放在上下文中的key
+const JWTClaimsKey = "claims"
+
+var (
+	ErrTokenMalformed   = errors.New("gee: jwt is malformed")
+	ErrTokenAlgorithm   = errors.New("gee: jwt algorithm is not allowed")
+	ErrTokenKeyNotFound = errors.New("gee: jwt signing key not found")
+	ErrTokenSignature   = errors.New("gee: jwt signature is invalid")
+	ErrTokenExpired     = errors.New("gee: jwt is expired")
+	ErrTokenNotValidYet = errors.New("gee: jwt is not valid yet")
+	ErrTokenIssuer      = errors.New("gee: jwt issuer is invalid")
+	ErrTokenAudience    = errors.New("gee: jwt audience is invalid")
+)
+
+// JWT里的payload部分, 数字类型为json.Number
+type Claims map[string]interface{}
+
+// 取出subject
+func (cl Claims) Subject() string {
+	s, _ := cl["sub"].(string)
+	return s
+}
+
+type JWTConfig struct {
+	// HS256使用的密钥
+	Secret []byte
+	// 固定的公钥, kid -> *rsa.PublicKey / *ecdsa.PublicKey / []byte
+	// token没有kid时使用key为""的那一个
+	Keys map[string]interface{}
+	// JWKS文件路径, 文件修改后会自动重新加载, 用于密钥轮换
+	JWKSFile string
+	// 检查JWKS文件是否修改的间隔, 默认1分钟, 遇到未知的kid时会立即检查
+	JWKSRefresh time.Duration
+	// 未知的kid触发立即检查的最小间隔, 默认5秒, 防止伪造的kid让所有请求都去读文件
+	JWKSMinRefresh time.Duration
+	// 允许的算法, 默认 HS256 / RS256 / ES256 里配置了密钥的那些
+	Algorithms []string
+	// 不为空时校验iss
+	Issuer string
+	// 不为空时校验aud
+	Audience string
+	// 校验exp和nbf时允许的时钟偏差
+	Leeway time.Duration
+	// 获取当前时间, 方便测试
+	Now func() time.Time
+}
+
+// 解析并校验JWT
+type JWTVerifier struct {
+	config JWTConfig
+
+	mu        sync.RWMutex
+	jwks      map[string]interface{}
+	jwksMod   time.Time
+	lastCheck time.Time
+	// 上一次因为未知的kid立即检查的时间
+	lastForced time.Time
+}
+
+func NewJWTVerifier(config JWTConfig) (*JWTVerifier, error) {
+	if config.JWKSRefresh == 0 {
+		config.JWKSRefresh = time.Minute
+	}
+	if config.JWKSMinRefresh == 0 {
+		config.JWKSMinRefresh = 5 * time.Second
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	if len(config.Algorithms) == 0 {
+		if len(config.Secret) > 0 {
+			config.Algorithms = append(config.Algorithms, "HS256")
+		}
+		if len(config.Keys) > 0 || config.JWKSFile != "" {
+			config.Algorithms = []string{"HS256", "RS256", "ES256"}
+		}
+	}
+	if len(config.Algorithms) == 0 {
+		return nil, errors.New("gee: jwt needs Secret, Keys or JWKSFile")
+	}
+	v := &JWTVerifier{config: config}
+	if config.JWKSFile != "" {
+		if err := v.reloadJWKS(true); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// JWT中间件, 校验通过后claims存放在 c.Keys[JWTClaimsKey], sub存放在 c.Keys[AuthUserKey]
+func JWT(config JWTConfig) HandlerFunc {
+	v, err := NewJWTVerifier(config)
+	if err != nil {
+		panic(err)
+	}
+	return BearerAuth(func(c *Context, token string) (interface{}, error) {
+		claims, err := v.Verify(token)
+		if err != nil {
+			return nil, err
+		}
+		c.Set(JWTClaimsKey, claims)
+		return claims.Subject(), nil
+	})
+}
+
+// 取出JWT中间件解析出的claims
+func (c *Context) Claims() Claims {
+	if val, ok := c.Get(JWTClaimsKey); ok {
+		claims, _ := val.(Claims)
+		return claims
+	}
+	return nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+}
+
+// 校验token, 返回其中的claims
+func (v *JWTVerifier) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	if !v.allowed(header.Alg) {
+		return nil, ErrTokenAlgorithm
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	key, err := v.key(header)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	if err := v.validateClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *JWTVerifier) allowed(alg string) bool {
+	for _, a := range v.config.Algorithms {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
+
+// 根据alg和kid找到校验用的密钥
+func (v *JWTVerifier) key(header jwtHeader) (interface{}, error) {
+	if header.Kid == "" && header.Alg == "HS256" && len(v.config.Secret) > 0 {
+		return v.config.Secret, nil
+	}
+	if key, ok := v.config.Keys[header.Kid]; ok {
+		return key, nil
+	}
+	if v.config.JWKSFile == "" {
+		return nil, ErrTokenKeyNotFound
+	}
+	if key, ok := v.lookupJWKS(header.Kid); ok {
+		return key, nil
+	}
+	// 可能是刚轮换的密钥, 立即检查一次文件, 但是限制检查的频率
+	if !v.allowForcedReload() {
+		return nil, ErrTokenKeyNotFound
+	}
+	if err := v.reloadJWKS(true); err != nil {
+		return nil, err
+	}
+	if key, ok := v.lookupJWKS(header.Kid); ok {
+		return key, nil
+	}
+	return nil, ErrTokenKeyNotFound
+}
+
+func (v *JWTVerifier) lookupJWKS(kid string) (interface{}, bool) {
+	if v.config.Now().Sub(v.checkedAt()) > v.config.JWKSRefresh {
+		v.reloadJWKS(false)
+	}
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	key, ok := v.jwks[kid]
+	return key, ok
+}
+
+// 距离上次立即检查超过JWKSMinRefresh时返回true, 并记录这次检查
+func (v *JWTVerifier) allowForcedReload() bool {
+	now := v.config.Now()
+	v.mu.RLock()
+	recent := now.Sub(v.lastForced) < v.config.JWKSMinRefresh
+	v.mu.RUnlock()
+	if recent {
+		return false
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	// 其他goroutine可能已经抢先检查了
+	if now.Sub(v.lastForced) < v.config.JWKSMinRefresh {
+		return false
+	}
+	v.lastForced = now
+	return true
+}
+
+func (v *JWTVerifier) checkedAt() time.Time {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.lastCheck
+}
+
+// 文件的修改时间变化时重新加载JWKS
+// force为false时加载失败会继续使用旧的密钥
+func (v *JWTVerifier) reloadJWKS(force bool) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.lastCheck = v.config.Now()
+	info, err := os.Stat(v.config.JWKSFile)
+	if err != nil {
+		if force && v.jwks == nil {
+			return err
+		}
+		return nil
+	}
+	if v.jwks != nil && info.ModTime().Equal(v.jwksMod) {
+		return nil
+	}
+	data, err := os.ReadFile(v.config.JWKSFile)
+	if err != nil {
+		return err
+	}
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		if v.jwks == nil {
+			return err
+		}
+		return nil
+	}
+	v.jwks = keys
+	v.jwksMod = info.ModTime()
+	return nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	// 对称密钥
+	K string `json:"k"`
+}
+
+// 解析JWKS文档, 返回 kid -> 公钥
+// 用途(use)不是sig的密钥会被忽略
+func ParseJWKS(data []byte) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("gee: jwks key %q: %v", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() > 1<<31-1 || exp.Int64() < 3 {
+			return nil, errors.New("invalid rsa exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("invalid ec point")
+		}
+		// 未压缩的点格式: 0x04 || X || Y
+		point := append(append([]byte{4}, x...), y...)
+		return ecdsa.ParseUncompressedPublicKey(elliptic.P256(), point)
+	case "oct":
+		return base64.RawURLEncoding.DecodeString(k.K)
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+// 校验签名, key的类型必须和alg匹配, 防止用公钥当HMAC密钥的攻击
+func verifySignature(alg string, key interface{}, signingInput string, sig []byte) error {
+	hash := sha256.Sum256([]byte(signingInput))
+	switch alg {
+	case "HS256":
+		secret, ok := key.([]byte)
+		if !ok {
+			return ErrTokenKeyNotFound
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signingInput))
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return ErrTokenSignature
+		}
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrTokenKeyNotFound
+		}
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, hash[:], sig); err != nil {
+			return ErrTokenSignature
+		}
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return ErrTokenKeyNotFound
+		}
+		// JWS里的ES256签名是定长的 r || s
+		if len(sig) != 64 {
+			return ErrTokenSignature
+		}
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(pub, hash[:], r, s) {
+			return ErrTokenSignature
+		}
+	default:
+		return ErrTokenAlgorithm
+	}
+	return nil
+}
+
+// 校验 exp / nbf / iss / aud
+func (v *JWTVerifier) validateClaims(claims Claims) error {
+	now := v.config.Now()
+	exp, hasExp, err := numericDate(claims["exp"])
+	if err != nil {
+		return err
+	}
+	if hasExp && now.After(exp.Add(v.config.Leeway)) {
+		return ErrTokenExpired
+	}
+	nbf, hasNbf, err := numericDate(claims["nbf"])
+	if err != nil {
+		return err
+	}
+	if hasNbf && now.Add(v.config.Leeway).Before(nbf) {
+		return ErrTokenNotValidYet
+	}
+	if v.config.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != v.config.Issuer {
+			return ErrTokenIssuer
+		}
+	}
+	if v.config.Audience != "" && !hasAudience(claims["aud"], v.config.Audience) {
+		return ErrTokenAudience
+	}
+	return nil
+}
+
+// aud可以是字符串, 也可以是字符串数组
+func hasAudience(aud interface{}, want string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == want
+	case []interface{}:
+		for _, a := range aud {
+			if s, ok := a.(string); ok && s == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 没有这个claim时返回false, 不是数字时返回ErrTokenMalformed, 不能当作没有设置
+func numericDate(val interface{}) (time.Time, bool, error) {
+	if val == nil {
+		return time.Time{}, false, nil
+	}
+	n, ok := val.(json.Number)
+	if !ok {
+		return time.Time{}, false, ErrTokenMalformed
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false, ErrTokenMalformed
+	}
+	sec := int64(f)
+	return time.Unix(sec, int64((f-float64(sec))*1e9)), true, nil
+}
+
+// base64url解码后再做json解析, 数字保留为json.Number
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
@@ -0,0 +1,144 @@
+package gee
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var b64 = base64.RawURLEncoding
+
+// 测试用的签名函数
+func signJWT(t *testing.T, alg, kid string, key interface{}, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := b64.EncodeToString(header) + "." + b64.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(input))
+	var sig []byte
+	switch alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write([]byte(input))
+		sig = mac.Sum(nil)
+	case "RS256":
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, hash[:]); err != nil {
+			t.Fatal(err)
+		}
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, key.(*ecdsa.PrivateKey), hash[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+	return input + "." + b64.EncodeToString(sig)
+}
+
+func TestJWTVerifyHS256(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	v, err := NewJWTVerifier(JWTConfig{
+		Secret:   []byte("secret"),
+		Issuer:   "gee",
+		Audience: "api",
+		Now:      func() time.Time { return now },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := map[string]interface{}{"sub": "geektutu", "iss": "gee", "aud": []string{"web", "api"}, "exp": now.Unix() + 60}
+	got, err := v.Verify(signJWT(t, "HS256", "", []byte("secret"), claims))
+	if err != nil || got.Subject() != "geektutu" {
+		t.Fatalf("expect valid token, got %v %v", got, err)
+	}
+
+	cases := []struct {
+		token string
+		err   error
+	}{
+		{signJWT(t, "HS256", "", []byte("other"), claims), ErrTokenSignature},
+		{signJWT(t, "HS256", "", []byte("secret"), map[string]interface{}{"iss": "gee", "aud": "api", "exp": now.Unix() - 1}), ErrTokenExpired},
+		{signJWT(t, "HS256", "", []byte("secret"), map[string]interface{}{"iss": "gee", "aud": "api", "nbf": now.Unix() + 60}), ErrTokenNotValidYet},
+		// 不是数字的exp/nbf不能被忽略
+		{signJWT(t, "HS256", "", []byte("secret"), map[string]interface{}{"iss": "gee", "aud": "api", "exp": "never"}), ErrTokenMalformed},
+		{signJWT(t, "HS256", "", []byte("secret"), map[string]interface{}{"iss": "gee", "aud": "api", "nbf": true}), ErrTokenMalformed},
+		{signJWT(t, "HS256", "", []byte("secret"), map[string]interface{}{"iss": "evil", "aud": "api"}), ErrTokenIssuer},
+		{signJWT(t, "HS256", "", []byte("secret"), map[string]interface{}{"iss": "gee", "aud": "web"}), ErrTokenAudience},
+		{b64.EncodeToString([]byte(`{"alg":"none"}`)) + ".e30.", ErrTokenAlgorithm},
+		{"not-a-token", ErrTokenMalformed},
+	}
+	for i, c := range cases {
+		if _, err := v.Verify(c.token); err != c.err {
+			t.Fatalf("case %d: expect %v, got %v", i, c.err, err)
+		}
+	}
+}
+
+func TestJWTVerifyJWKSRotation(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecPoint, _ := ecKey.PublicKey.Bytes()
+
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS := func(keys ...map[string]string) {
+		data, _ := json.Marshal(map[string]interface{}{"keys": keys})
+		if err := os.WriteFile(file, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rsaJWK := map[string]string{
+		"kty": "RSA", "kid": "r1", "use": "sig",
+		"n": b64.EncodeToString(rsaKey.N.Bytes()),
+		"e": b64.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+	}
+	ecJWK := map[string]string{
+		"kty": "EC", "kid": "e1", "crv": "P-256",
+		"x": b64.EncodeToString(ecPoint[1:33]),
+		"y": b64.EncodeToString(ecPoint[33:]),
+	}
+	writeJWKS(rsaJWK)
+
+	now := time.Now()
+	v, err := NewJWTVerifier(JWTConfig{JWKSFile: file, Now: func() time.Time { return now }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := map[string]interface{}{"sub": "geektutu"}
+	if _, err := v.Verify(signJWT(t, "RS256", "r1", rsaKey, claims)); err != nil {
+		t.Fatalf("RS256: %v", err)
+	}
+	esToken := signJWT(t, "ES256", "e1", ecKey, claims)
+	if _, err := v.Verify(esToken); err != ErrTokenKeyNotFound {
+		t.Fatalf("expect unknown kid, got %v", err)
+	}
+
+	// 轮换密钥, 未知的kid会触发重新加载
+	writeJWKS(rsaJWK, ecJWK)
+	later := time.Now().Add(time.Second)
+	os.Chtimes(file, later, later)
+	// 刚刚因为未知的kid检查过, JWKSMinRefresh内不会再检查
+	if _, err := v.Verify(esToken); err != ErrTokenKeyNotFound {
+		t.Fatalf("forced reload should be rate limited, got %v", err)
+	}
+	now = now.Add(6 * time.Second)
+	if _, err := v.Verify(esToken); err != nil {
+		t.Fatalf("ES256 after rotation: %v", err)
+	}
+	// RS256的kid不能用来校验ES256签名
+	if _, err := v.Verify(signJWT(t, "ES256", "r1", ecKey, claims)); err != ErrTokenKeyNotFound {
+		t.Fatalf("expect key type mismatch, got %v", err)
+	}
+}
@@ -10,6 +10,6 @@ func Logger() HandlerFunc {
 		t := time.Now()
 		ctx.Next()
 		// Calculate resolution time
-		log.Printf("[%d] %s in %v", ctx.StatusCode, ctx.Req.RequestURI, time.Since(t))
+		log.Printf("[%d] %s %s in %v", ctx.StatusCode, ctx.ClientIP(), ctx.Req.RequestURI, time.Since(t))
 	}
 }
\ No newline at end of file
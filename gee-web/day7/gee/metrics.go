@@ -0,0 +1,222 @@
+// Prometheus格式的监控指标, 不依赖Prometheus的客户端库
+package gee
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type MetricsConfig struct {
+	// 暴露指标的路径, 默认 /metrics
+	Path string
+	// 指标名的前缀, 默认 gee
+	Namespace string
+	// 请求耗时(秒)的分桶, 默认和Prometheus客户端的默认值相同
+	DurationBuckets []float64
+	// 响应大小(字节)的分桶, 默认 100B 到 100MB
+	SizeBuckets []float64
+}
+
+// 默认的配置
+func DefaultMetricsConfig() MetricsConfig {
+	return MetricsConfig{
+		Path:            "/metrics",
+		Namespace:       "gee",
+		DurationBuckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		SizeBuckets:     []float64{100, 1e3, 1e4, 1e5, 1e6, 1e7, 1e8},
+	}
+}
+
+// 使用默认配置的监控中间件, 指标在 /metrics
+func Metrics() HandlerFunc {
+	return MetricsWithConfig(DefaultMetricsConfig())
+}
+
+// 记录请求数, 耗时和响应大小, 标签为 method, path, status, 非标准的method记为OTHER
+// path使用匹配到的路由规则(c.FullPath), 而不是原始路径, 避免标签的值无限增长, 没有匹配到路由时为空
+// 请求config.Path时返回Prometheus文本格式的指标, 这个请求本身不会被记录
+// 需要注册在Engine上, 才能拦截到没有注册路由的 config.Path
+func MetricsWithConfig(config MetricsConfig) HandlerFunc {
+	defaults := DefaultMetricsConfig()
+	if config.Path == "" {
+		config.Path = defaults.Path
+	}
+	if config.Namespace == "" {
+		config.Namespace = defaults.Namespace
+	}
+	if len(config.DurationBuckets) == 0 {
+		config.DurationBuckets = defaults.DurationBuckets
+	}
+	if len(config.SizeBuckets) == 0 {
+		config.SizeBuckets = defaults.SizeBuckets
+	}
+	m := &metrics{config: config, series: make(map[metricLabels]*metricSeries)}
+
+	return func(c *Context) {
+		if c.Req.URL.Path == config.Path && (c.Method == http.MethodGet || c.Method == http.MethodHead) {
+			c.Abort()
+			c.SetHeader("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+			c.Status(http.StatusOK)
+			m.writeTo(c.Writer)
+			return
+		}
+
+		start := time.Now()
+		atomic.AddInt64(&m.inFlight, 1)
+		defer atomic.AddInt64(&m.inFlight, -1)
+		w := c.Writer
+		mw := &statusWriter{ResponseWriter: w}
+		c.Writer = mw
+		defer func() {
+			c.Writer = w
+			status := mw.status
+			if status == 0 {
+				status = c.StatusCode
+			}
+			if status == 0 {
+				status = http.StatusOK
+			}
+			labels := metricLabels{method: metricMethod(c.Method), path: c.FullPath(), status: strconv.Itoa(status)}
+			m.observe(labels, time.Since(start).Seconds(), float64(mw.size))
+		}()
+		c.Next()
+	}
+}
+
+// 客户端可以发送任意的method, 非标准的method统一记为OTHER, 避免指标数量无限增长
+func metricMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions, http.MethodConnect, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
+
+type metricLabels struct {
+	method, path, status string
+}
+
+// 一组标签对应的指标
+type metricSeries struct {
+	requests uint64
+	duration *histogram
+	size     *histogram
+}
+
+type metrics struct {
+	config   MetricsConfig
+	inFlight int64
+
+	mu     sync.Mutex
+	series map[metricLabels]*metricSeries
+}
+
+func (m *metrics) observe(labels metricLabels, seconds float64, size float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.series[labels]
+	if !ok {
+		s = &metricSeries{duration: newHistogram(m.config.DurationBuckets), size: newHistogram(m.config.SizeBuckets)}
+		m.series[labels] = s
+	}
+	s.requests++
+	s.duration.observe(seconds)
+	s.size.observe(size)
+}
+
+// 输出Prometheus文本格式, 标签按字典序排列, 输出是稳定的
+func (m *metrics) writeTo(w http.ResponseWriter) {
+	m.mu.Lock()
+	labels := make([]metricLabels, 0, len(m.series))
+	for l := range m.series {
+		labels = append(labels, l)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		a, b := labels[i], labels[j]
+		if a.path != b.path {
+			return a.path < b.path
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.status < b.status
+	})
+	var b strings.Builder
+	ns := m.config.Namespace
+
+	name := ns + "_http_requests_total"
+	fmt.Fprintf(&b, "# HELP %s Total number of HTTP requests.\n# TYPE %s counter\n", name, name)
+	for _, l := range labels {
+		fmt.Fprintf(&b, "%s{%s} %d\n", name, l.String(), m.series[l].requests)
+	}
+
+	name = ns + "_http_request_duration_seconds"
+	fmt.Fprintf(&b, "# HELP %s HTTP request latency in seconds.\n# TYPE %s histogram\n", name, name)
+	for _, l := range labels {
+		m.series[l].duration.writeTo(&b, name, l.String())
+	}
+
+	name = ns + "_http_response_size_bytes"
+	fmt.Fprintf(&b, "# HELP %s HTTP response size in bytes.\n# TYPE %s histogram\n", name, name)
+	for _, l := range labels {
+		m.series[l].size.writeTo(&b, name, l.String())
+	}
+	m.mu.Unlock()
+
+	name = ns + "_http_requests_in_flight"
+	fmt.Fprintf(&b, "# HELP %s Number of HTTP requests being served.\n# TYPE %s gauge\n", name, name)
+	fmt.Fprintf(&b, "%s %d\n", name, atomic.LoadInt64(&m.inFlight))
+
+	w.Write([]byte(b.String()))
+}
+
+func (l metricLabels) String() string {
+	return fmt.Sprintf(`method="%s",path="%s",status="%s"`, escapeLabel(l.method), escapeLabel(l.path), escapeLabel(l.status))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+type histogram struct {
+	buckets []float64
+	// 每个分桶中的数量, 不是累计值
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	return &histogram{buckets: sorted, counts: make([]uint64, len(sorted))}
+}
+
+func (h *histogram) observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v)
+	if i < len(h.buckets) {
+		h.counts[i]++
+	}
+	h.sum += v
+	h.count++
+}
+
+func (h *histogram) writeTo(b *strings.Builder, name string, labels string) {
+	var cumulative uint64
+	for i, le := range h.buckets {
+		cumulative += h.counts[i]
+		fmt.Fprintf(b, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+	}
+	fmt.Fprintf(b, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	fmt.Fprintf(b, "%s_sum{%s} %s\n", name, labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(b, "%s_count{%s} %d\n", name, labels, h.count)
+}
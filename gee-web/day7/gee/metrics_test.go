@@ -0,0 +1,74 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	r := New()
+	r.Use(MetricsWithConfig(MetricsConfig{Path: "/internal/metrics", DurationBuckets: []float64{0.1, 1}}))
+	r.GET("/users/:id", func(c *Context) {
+		c.String(http.StatusOK, "user %s", c.Param("id"))
+	})
+	r.GET("/direct", WrapF(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	for _, path := range []string{"/users/1", "/users/2", "/direct", "/missing"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/internal/metrics", nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("expect metrics page, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	body := w.Body.String()
+	for _, line := range []string{
+		"# TYPE gee_http_requests_total counter",
+		`gee_http_requests_total{method="GET",path="/users/:id",status="200"} 2`,
+		`gee_http_requests_total{method="GET",path="/direct",status="202"} 1`,
+		`gee_http_requests_total{method="GET",path="",status="404"} 1`,
+		"# TYPE gee_http_request_duration_seconds histogram",
+		`gee_http_request_duration_seconds_bucket{method="GET",path="/users/:id",status="200",le="1"} 2`,
+		`gee_http_request_duration_seconds_bucket{method="GET",path="/users/:id",status="200",le="+Inf"} 2`,
+		`gee_http_request_duration_seconds_count{method="GET",path="/users/:id",status="200"} 2`,
+		`gee_http_response_size_bytes_bucket{method="GET",path="/users/:id",status="200",le="100"} 2`,
+		`gee_http_response_size_bytes_sum{method="GET",path="/users/:id",status="200"} 12`,
+		"gee_http_requests_in_flight 0",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, "/users/1") || strings.Contains(body, "/internal/metrics") {
+		t.Fatalf("raw paths and the metrics endpoint should not be recorded:\n%s", body)
+	}
+}
+
+func TestMetricsMethod(t *testing.T) {
+	r := New()
+	r.Use(Metrics())
+	r.GET("/ping", func(c *Context) {
+		c.String(http.StatusOK, "pong")
+	})
+
+	for _, method := range []string{"FOO", "BAR", "BAZ"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/ping", nil))
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	if !strings.Contains(body, `gee_http_requests_total{method="OTHER",path="",status="404"} 3`+"\n") {
+		t.Fatalf("expect made-up methods to share one series:\n%s", body)
+	}
+	for _, method := range []string{"FOO", "BAR", "BAZ"} {
+		if strings.Contains(body, `method="`+method+`"`) {
+			t.Fatalf("method %s should not be a label value:\n%s", method, body)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// 运行模式: debug / release / test
+package gee
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// 通过这个环境变量设置运行模式
+const EnvGeeMode = "GEE_MODE"
+
+const (
+	// 打印路由表和匹配过程, 每次渲染都重新加载模板
+	DebugMode = "debug"
+	// 只打印必要的警告
+	ReleaseMode = "release"
+	// 测试时使用, 不打印任何信息
+	TestMode = "test"
+)
+
+var geeMode atomic.Value
+
+func init() {
+	SetMode(os.Getenv(EnvGeeMode))
+}
+
+// 设置运行模式, 为空时使用debug
+func SetMode(value string) {
+	if value == "" {
+		value = DebugMode
+	}
+	switch value {
+	case DebugMode, ReleaseMode, TestMode:
+		geeMode.Store(value)
+	default:
+		panic("gee: unknown mode " + value + ", available modes: debug release test")
+	}
+}
+
+// 当前的运行模式
+func Mode() string {
+	return geeMode.Load().(string)
+}
+
+func IsDebugging() bool {
+	return Mode() == DebugMode
+}
+
+// debug模式下打印调试信息
+func debugPrint(format string, values ...interface{}) {
+	if IsDebugging() {
+		if !strings.HasSuffix(format, "\n") {
+			format += "\n"
+		}
+		log.Printf("[GEE-debug] "+format, values...)
+	}
+}
+
+// 打印配置错误之类的警告, 只有test模式下不打印
+func warnPrint(format string, values ...interface{}) {
+	if Mode() != TestMode {
+		log.Printf("[GEE-WARNING] %s", fmt.Sprintf(format, values...))
+	}
+}
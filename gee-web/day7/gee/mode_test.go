@@ -0,0 +1,46 @@
+package gee
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecoveryHidesStack(t *testing.T) {
+	defer SetMode(TestMode)
+	panicky := func(c *Context) {
+		panic("boom")
+	}
+	r := New()
+	r.Use(Recovery())
+	r.GET("/panic", panicky)
+
+	// 任何模式下都不把调用栈返回给客户端
+	for _, mode := range []string{DebugMode, ReleaseMode} {
+		SetMode(mode)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/panic", nil))
+		if w.Code != 500 || strings.Contains(w.Body.String(), "Traceback") {
+			t.Fatalf("%s mode: unexpected body %q", mode, w.Body.String())
+		}
+	}
+
+	SetMode(TestMode)
+	r = New()
+	r.Use(RecoveryWithConfig(RecoveryConfig{ShowStack: true}))
+	r.GET("/panic", panicky)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/panic", nil))
+	if w.Code != 500 || !strings.Contains(w.Body.String(), "Traceback") {
+		t.Fatalf("ShowStack: expect stack in body, got %q", w.Body.String())
+	}
+}
+
+func TestSetModeInvalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("unknown mode should panic")
+		}
+	}()
+	SetMode("prod")
+}
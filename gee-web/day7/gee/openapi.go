@@ -0,0 +1,292 @@
+// 根据路由和文档信息生成OpenAPI 3.1文档, 不依赖swagger
+package gee
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const openAPIVersion = "3.1.0"
+
+// 文档的基本信息
+type OpenAPIInfo struct {
+	// 默认 gee
+	Title string
+	// 默认 1.0.0
+	Version     string
+	Description string
+}
+
+// 生成OpenAPI 3.1的JSON文档
+// 请求和响应的结构体通过反射生成JSON Schema, 字段名使用json标签, 没有omitempty的字段是必填的
+func (engine *Engine) OpenAPI(info OpenAPIInfo) ([]byte, error) {
+	if info.Title == "" {
+		info.Title = "gee"
+	}
+	if info.Version == "" {
+		info.Version = "1.0.0"
+	}
+	apiInfo := H{"title": info.Title, "version": info.Version}
+	if info.Description != "" {
+		apiInfo["description"] = info.Description
+	}
+
+	g := &schemaGenerator{schemas: make(H), names: make(map[reflect.Type]string)}
+	paths := make(map[string]H)
+	for _, route := range engine.routes {
+		if route.hidden {
+			continue
+		}
+		path := openAPIPath(route.Path)
+		if paths[path] == nil {
+			paths[path] = make(H)
+		}
+		paths[path][strings.ToLower(route.Method)] = g.operation(route)
+	}
+
+	doc := H{
+		"openapi": openAPIVersion,
+		"info":    apiInfo,
+		"paths":   paths,
+	}
+	if len(g.schemas) > 0 {
+		doc["components"] = H{"schemas": g.schemas}
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// 注册一个返回OpenAPI文档的路由, 每次请求时根据当前的路由生成
+//
+//	r.OpenAPIDoc("/openapi.json", gee.OpenAPIInfo{Title: "blog", Version: "1.0.0"})
+func (rg *RouterGroup) OpenAPIDoc(relativePath string, info OpenAPIInfo) *Route {
+	engine := rg.engine
+	return rg.GET(relativePath, func(c *Context) {
+		doc, err := engine.OpenAPI(info)
+		if err != nil {
+			c.Fail(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.SetHeader("Content-Type", "application/json")
+		c.Data(http.StatusOK, doc)
+	}).Hidden()
+}
+
+// /users/:id/*filepath 转换为 /users/{id}/{filepath}
+func openAPIPath(pattern string) string {
+	parts := strings.Split(pattern, "/")
+	for i, part := range parts {
+		if len(part) > 1 && (part[0] == ':' || part[0] == '*') {
+			parts[i] = "{" + part[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func (g *schemaGenerator) operation(route *Route) H {
+	op := make(H)
+	if route.summary != "" {
+		op["summary"] = route.summary
+	}
+	if route.description != "" {
+		op["description"] = route.description
+	}
+	if route.operationID != "" {
+		op["operationId"] = route.operationID
+	}
+	if len(route.tags) > 0 {
+		op["tags"] = route.tags
+	}
+	if route.deprecated {
+		op["deprecated"] = true
+	}
+
+	if params := g.parameters(route); len(params) > 0 {
+		op["parameters"] = params
+	}
+	if route.request != nil && route.hasBody() {
+		op["requestBody"] = H{
+			"required": true,
+			"content":  H{"application/json": H{"schema": g.schema(reflect.TypeOf(route.request))}},
+		}
+	}
+
+	responses := make(H)
+	for _, resp := range route.responses {
+		r := H{"description": http.StatusText(resp.code)}
+		if resp.body != nil {
+			r["content"] = H{"application/json": H{"schema": g.schema(reflect.TypeOf(resp.body))}}
+		}
+		responses[strconv.Itoa(resp.code)] = r
+	}
+	if len(responses) == 0 {
+		responses["200"] = H{"description": http.StatusText(http.StatusOK)}
+	}
+	op["responses"] = responses
+	return op
+}
+
+// 路由规则中的path参数, 没有请求体时请求结构体中的query参数, 以及手动添加的参数
+func (g *schemaGenerator) parameters(route *Route) []H {
+	var params []H
+	index := make(map[string]H)
+	add := func(in, name string, required bool, schema H) H {
+		key := in + ":" + name
+		if p, ok := index[key]; ok {
+			return p
+		}
+		p := H{"name": name, "in": in, "schema": schema}
+		if required {
+			p["required"] = true
+		}
+		index[key] = p
+		params = append(params, p)
+		return p
+	}
+
+	for _, part := range parsePattern(route.Path) {
+		if len(part) > 1 && (part[0] == ':' || part[0] == '*') {
+			add("path", part[1:], true, H{"type": "string"})
+		}
+	}
+	if route.request != nil && !route.hasBody() {
+		t := reflect.TypeOf(route.request)
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() == reflect.Struct {
+			for _, f := range reflect.VisibleFields(t) {
+				name, _, _ := strings.Cut(f.Tag.Get("form"), ",")
+				if name == "" || name == "-" || !f.IsExported() {
+					continue
+				}
+				add("query", name, false, g.schema(f.Type))
+			}
+		}
+	}
+	for _, p := range route.params {
+		param := add(p.in, p.name, p.in == "path", H{"type": "string"})
+		if p.description != "" {
+			param["description"] = p.description
+		}
+	}
+	return params
+}
+
+// 通过反射生成JSON Schema, 具名的结构体放在 components/schemas 里引用
+type schemaGenerator struct {
+	schemas H
+	names   map[reflect.Type]string
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (g *schemaGenerator) schema(t reflect.Type) H {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return H{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return H{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		schema := H{"type": "integer"}
+		if t.Kind() == reflect.Int64 || t.Kind() == reflect.Uint64 {
+			schema["format"] = "int64"
+		} else if t.Kind() == reflect.Int32 || t.Kind() == reflect.Uint32 {
+			schema["format"] = "int32"
+		}
+		return schema
+	case reflect.Float32, reflect.Float64:
+		return H{"type": "number"}
+	case reflect.String:
+		return H{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json把[]byte编码为base64
+			return H{"type": "string", "contentEncoding": "base64"}
+		}
+		return H{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return H{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name, ok := g.names[t]
+		if !ok {
+			name = g.schemaName(t)
+			g.names[t] = name
+			// 先占位, 防止递归的结构体死循环
+			g.schemas[name] = H{}
+			g.schemas[name] = g.structSchema(t)
+		}
+		return H{"$ref": "#/components/schemas/" + name}
+	}
+	// interface{} 等任意类型
+	return H{}
+}
+
+// 不同包中同名的结构体加上包名区分
+func (g *schemaGenerator) schemaName(t reflect.Type) string {
+	name := t.Name()
+	if _, exists := g.schemas[name]; !exists {
+		return name
+	}
+	pkg := t.PkgPath()
+	if i := strings.LastIndexByte(pkg, '/'); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	return pkg + "." + name
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) H {
+	properties := make(H)
+	var required []string
+	for _, f := range reflect.VisibleFields(t) {
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if !f.IsExported() || f.Anonymous && ft.Kind() == reflect.Struct && f.Tag.Get("json") == "" {
+			// 嵌入的结构体字段已经包含在VisibleFields里
+			continue
+		}
+		if len(f.Index) > 1 && !promotedJSONField(t, f) {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = g.schema(f.Type)
+		if !strings.Contains(opts, "omitempty") && !strings.Contains(opts, "omitzero") {
+			required = append(required, name)
+		}
+	}
+	schema := H{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// 嵌入结构体的字段只有在嵌入时没有json标签(被展开)时才属于外层结构体
+func promotedJSONField(t reflect.Type, f reflect.StructField) bool {
+	for i := 1; i < len(f.Index); i++ {
+		embedded := t.FieldByIndex(f.Index[:i])
+		if embedded.Tag.Get("json") != "" {
+			return false
+		}
+	}
+	return true
+}
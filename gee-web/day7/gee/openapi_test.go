@@ -0,0 +1,119 @@
+package gee
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type apiModel struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type apiUser struct {
+	apiModel
+	Name    string            `json:"name"`
+	Email   string            `json:"email,omitempty"`
+	Friends []*apiUser        `json:"friends,omitempty"`
+	Extra   map[string]string `json:"extra,omitempty"`
+	secret  string
+	Ignored string `json:"-"`
+}
+
+type apiListUsers struct {
+	Page int    `form:"page"`
+	Name string `form:"name"`
+}
+
+func TestOpenAPI(t *testing.T) {
+	r := New()
+	v1 := r.Group("/v1")
+	v1.GET("/users", func(c *Context) {}).
+		Summary("list users").
+		Tags("user").
+		Request(apiListUsers{}).
+		Response(http.StatusOK, []apiUser{})
+	v1.POST("/users", func(c *Context) {}).
+		Tags("user").
+		Request(&apiUser{}).
+		Response(http.StatusCreated, apiUser{}).
+		Response(http.StatusBadRequest, Problem{})
+	v1.GET("/users/:id", func(c *Context) {}).
+		Param("path", "id", "user id").
+		Deprecated()
+	r.GET("/internal", func(c *Context) {}).Hidden()
+	r.OpenAPIDoc("/openapi.json", OpenAPIInfo{Title: "test"})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/openapi.json", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("expect json document, got %d", w.Code)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	get := func(path ...string) interface{} {
+		var v interface{} = doc
+		for _, p := range path {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				t.Fatalf("%v is not an object", path)
+			}
+			v = m[p]
+		}
+		return v
+	}
+
+	if doc["openapi"] != "3.1.0" || get("info", "title") != "test" || get("info", "version") != "1.0.0" {
+		t.Fatalf("unexpected header: %v %v", doc["openapi"], doc["info"])
+	}
+	paths := get("paths").(map[string]interface{})
+	if len(paths) != 2 {
+		t.Fatalf("expect 2 documented paths, got %v", paths)
+	}
+
+	params := get("paths", "/v1/users", "get", "parameters").([]interface{})
+	if len(params) != 2 || params[0].(map[string]interface{})["in"] != "query" {
+		t.Fatalf("expect query parameters from form tags, got %v", params)
+	}
+	param := get("paths", "/v1/users/{id}", "get", "parameters").([]interface{})[0].(map[string]interface{})
+	if param["in"] != "path" || param["required"] != true || param["description"] != "user id" {
+		t.Fatalf("unexpected path parameter: %v", param)
+	}
+	if get("paths", "/v1/users/{id}", "get", "deprecated") != true {
+		t.Fatal("expect deprecated")
+	}
+	if ref := get("paths", "/v1/users", "post", "requestBody", "content", "application/json", "schema", "$ref"); ref != "#/components/schemas/apiUser" {
+		t.Fatalf("unexpected request schema: %v", ref)
+	}
+	if items := get("paths", "/v1/users", "get", "responses", "200", "content", "application/json", "schema", "items", "$ref"); items != "#/components/schemas/apiUser" {
+		t.Fatalf("unexpected response schema: %v", items)
+	}
+
+	user := get("components", "schemas", "apiUser").(map[string]interface{})
+	props := user["properties"].(map[string]interface{})
+	var names []string
+	for name := range props {
+		names = append(names, name)
+	}
+	want := map[string]bool{"id": true, "created_at": true, "name": true, "email": true, "friends": true, "extra": true}
+	if len(props) != len(want) {
+		t.Fatalf("unexpected properties: %v", names)
+	}
+	for name := range want {
+		if props[name] == nil {
+			t.Fatalf("missing property %s in %v", name, names)
+		}
+	}
+	if !reflect.DeepEqual(user["required"], []interface{}{"id", "created_at", "name"}) {
+		t.Fatalf("unexpected required: %v", user["required"])
+	}
+	if get("components", "schemas", "apiUser", "properties", "created_at", "format") != "date-time" {
+		t.Fatal("expect time.Time as date-time")
+	}
+}
@@ -0,0 +1,238 @@
+// 限流中间件, 支持令牌桶和滑动窗口两种算法
+package gee
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 一次限流判断的结果
+type RateLimitInfo struct {
+	// 是否放行
+	Allowed bool
+	// 窗口内允许的请求数
+	Limit int
+	// 剩余的请求数
+	Remaining int
+	// 多久之后配额完全恢复
+	Reset time.Duration
+	// 被拒绝时, 多久之后可以重试
+	RetryAfter time.Duration
+}
+
+// 存放限流状态, 可以实现这个接口接入redis等外部存储
+type RateLimitStore interface {
+	// 对key消耗一次配额
+	Take(key string) (RateLimitInfo, error)
+}
+
+type RateLimitConfig struct {
+	// 限流状态的存储, 必填
+	Store RateLimitStore
+	// 用哪个维度来限流, 默认按客户端IP
+	KeyFunc func(c *Context) string
+	// Store出错时是否放行, 默认拒绝
+	FailOpen bool
+}
+
+// 限流中间件, 会设置 RateLimit-* 响应头, 超出限制时返回429和Retry-After
+func RateLimit(config RateLimitConfig) HandlerFunc {
+	if config.Store == nil {
+		panic("gee: RateLimit needs a store")
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = KeyByIP()
+	}
+	return func(c *Context) {
+		info, err := config.Store.Take(config.KeyFunc(c))
+		if err != nil {
+			log.Printf("gee: rate limit store error: %v", err)
+			if config.FailOpen {
+				c.Next()
+				return
+			}
+			c.Fail(http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+		c.SetHeader("RateLimit-Limit", strconv.Itoa(info.Limit))
+		c.SetHeader("RateLimit-Remaining", strconv.Itoa(info.Remaining))
+		c.SetHeader("RateLimit-Reset", strconv.Itoa(ceilSeconds(info.Reset)))
+		if !info.Allowed {
+			c.SetHeader("Retry-After", strconv.Itoa(ceilSeconds(info.RetryAfter)))
+			c.Fail(http.StatusTooManyRequests, http.StatusText(http.StatusTooManyRequests))
+			return
+		}
+		c.Next()
+	}
+}
+
+// 按客户端IP限流, 在代理后面时需要通过 SetTrustedProxies 配置代理
+func KeyByIP() func(c *Context) string {
+	return func(c *Context) string {
+		return c.ClientIP()
+	}
+}
+
+// 按请求头限流, 例如 X-API-Key
+func KeyByHeader(name string) func(c *Context) string {
+	return func(c *Context) string {
+		return c.Req.Header.Get(name)
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int(math.Ceil(d.Seconds()))
+}
+
+// 每个key的限流状态
+type rateEntry struct {
+	// 令牌桶: 剩余令牌数; 滑动窗口: 上一个窗口的计数
+	value float64
+	// 滑动窗口: 当前窗口的计数
+	count int
+	// 令牌桶: 上次补充令牌的时间; 滑动窗口: 当前窗口的开始时间
+	start time.Time
+	// 最后一次访问, 用来清理空闲的key
+	seen time.Time
+}
+
+// 内存里的限流存储, 会定期清理长时间没有访问的key
+type MemoryRateStore struct {
+	mu      sync.Mutex
+	entries map[string]*rateEntry
+	// 具体的限流算法
+	take func(e *rateEntry, now time.Time, fresh bool) RateLimitInfo
+	// 空闲多久之后清理
+	idle      time.Duration
+	lastSweep time.Time
+	// 获取当前时间, 方便测试
+	now func() time.Time
+}
+
+// 令牌桶: 桶容量为limit, 每个period匀速补满limit个令牌, 允许突发
+func NewTokenBucketStore(limit int, period time.Duration) *MemoryRateStore {
+	s := newMemoryRateStore(limit, period)
+	rate := float64(limit) / period.Seconds() // 每秒补充的令牌数
+	s.take = func(e *rateEntry, now time.Time, fresh bool) RateLimitInfo {
+		if fresh {
+			e.value = float64(limit)
+		} else {
+			e.value = math.Min(float64(limit), e.value+now.Sub(e.start).Seconds()*rate)
+		}
+		e.start = now
+		info := RateLimitInfo{Limit: limit}
+		if e.value >= 1 {
+			e.value--
+			info.Allowed = true
+		} else {
+			info.RetryAfter = secondsToDuration((1 - e.value) / rate)
+		}
+		info.Remaining = int(e.value)
+		info.Reset = secondsToDuration((float64(limit) - e.value) / rate)
+		return info
+	}
+	return s
+}
+
+// 滑动窗口计数: 用上一个窗口的计数按时间加权, 近似任意window内最多limit个请求
+func NewSlidingWindowStore(limit int, window time.Duration) *MemoryRateStore {
+	s := newMemoryRateStore(limit, window)
+	s.take = func(e *rateEntry, now time.Time, fresh bool) RateLimitInfo {
+		start := now.Truncate(window)
+		if fresh || !e.start.Equal(start) {
+			if !fresh && start.Sub(e.start) == window {
+				e.value = float64(e.count)
+			} else {
+				e.value = 0
+			}
+			e.count = 0
+			e.start = start
+		}
+		elapsed := now.Sub(start)
+		weight := 1 - float64(elapsed)/float64(window)
+		estimate := e.value*weight + float64(e.count)
+
+		info := RateLimitInfo{Limit: limit, Reset: window - elapsed}
+		if estimate+1 <= float64(limit) {
+			e.count++
+			estimate++
+			info.Allowed = true
+		} else if e.count+1 > limit || e.value == 0 {
+			// 当前窗口已经用完, 只能等下一个窗口
+			info.RetryAfter = window - elapsed
+		} else {
+			// 等上一个窗口的权重下降到足够放行一个请求
+			x := 1 - float64(limit-1-e.count)/e.value
+			info.RetryAfter = time.Duration(x*float64(window)) - elapsed
+		}
+		info.Remaining = limit - int(math.Ceil(estimate))
+		if info.Remaining < 0 {
+			info.Remaining = 0
+		}
+		return info
+	}
+	return s
+}
+
+func newMemoryRateStore(limit int, period time.Duration) *MemoryRateStore {
+	if limit <= 0 || period <= 0 {
+		panic("gee: rate limit needs a positive limit and period")
+	}
+	return &MemoryRateStore{
+		entries: make(map[string]*rateEntry),
+		idle:    2 * period,
+		now:     time.Now,
+	}
+}
+
+// 设置空闲多久后清理key, 默认是两个周期
+func (s *MemoryRateStore) SetIdleTimeout(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.idle = d
+}
+
+func (s *MemoryRateStore) Take(key string) (RateLimitInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.sweep(now)
+	e, ok := s.entries[key]
+	if !ok {
+		e = &rateEntry{}
+		s.entries[key] = e
+	}
+	e.seen = now
+	return s.take(e, now, !ok), nil
+}
+
+// 当前保存的key数量
+func (s *MemoryRateStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// 清理空闲的key, 最多每个idle周期扫描一次
+func (s *MemoryRateStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < s.idle {
+		return
+	}
+	s.lastSweep = now
+	for key, e := range s.entries {
+		if now.Sub(e.seen) >= s.idle {
+			delete(s.entries, key)
+		}
+	}
+}
+
+func secondsToDuration(sec float64) time.Duration {
+	return time.Duration(sec * float64(time.Second))
+}
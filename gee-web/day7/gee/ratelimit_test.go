@@ -0,0 +1,77 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketStore(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	s := NewTokenBucketStore(2, time.Second)
+	s.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if info, _ := s.Take("a"); !info.Allowed {
+			t.Fatalf("request %d should be allowed", i)
+		}
+	}
+	info, _ := s.Take("a")
+	if info.Allowed || info.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("expect denied with 500ms retry, got %+v", info)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if info, _ := s.Take("a"); !info.Allowed {
+		t.Fatal("bucket should refill one token")
+	}
+
+	// 空闲的key会被清理
+	now = now.Add(time.Hour)
+	s.Take("b")
+	if s.Len() != 1 {
+		t.Fatalf("idle key should be evicted, got %d keys", s.Len())
+	}
+}
+
+func TestSlidingWindowStore(t *testing.T) {
+	now := time.Unix(1700000000, 0).Truncate(time.Minute)
+	s := NewSlidingWindowStore(4, time.Minute)
+	s.now = func() time.Time { return now }
+
+	for i := 0; i < 4; i++ {
+		s.Take("a")
+	}
+	if info, _ := s.Take("a"); info.Allowed {
+		t.Fatal("5th request should be denied")
+	}
+	// 进入下一个窗口的一半, 上一个窗口的4个请求按一半计算
+	now = now.Add(90 * time.Second)
+	for i := 0; i < 2; i++ {
+		if info, _ := s.Take("a"); !info.Allowed {
+			t.Fatalf("request %d should be allowed", i)
+		}
+	}
+	if info, _ := s.Take("a"); info.Allowed {
+		t.Fatal("weighted window should be full")
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	r := New()
+	r.Use(RateLimit(RateLimitConfig{Store: NewTokenBucketStore(1, time.Minute)}))
+	r.GET("/", func(c *Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK || w.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("expect 200 with remaining 0, got %d %v", w.Code, w.Header())
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Fatalf("expect 429 with Retry-After 60, got %d %v", w.Code, w.Header())
+	}
+}
@@ -22,17 +22,36 @@ func trace(message string) string {
 }
 
 func (c *Context) Recover() HandlerFunc{
+	return Recovery()
+}
+
+type RecoveryConfig struct {
+	// 把调用栈返回给客户端, 只在本地调试时打开, 调用栈里有文件路径等内部信息
+	ShowStack bool
+}
+
+// 错误恢复中间件, 调用栈只打印到日志里, 客户端只会收到500
+func Recovery() HandlerFunc {
+	return RecoveryWithConfig(RecoveryConfig{})
+}
+
+func RecoveryWithConfig(config RecoveryConfig) HandlerFunc {
 	return func(c *Context) {
 		defer func(){
 			if err := recover(); err != nil {
 				// 说明发生错误
 				// 这里要捕获出错逻辑
 				message := fmt.Sprintf("%s", err)
-				log.Printf("%s\n\n", trace(message))
+				stack := trace(message)
+				log.Printf("%s\n\n", stack)
+				if config.ShowStack {
+					c.Fail(http.StatusInternalServerError, stack)
+					return
+				}
 				c.Fail(http.StatusInternalServerError, "Internal Server Error")
 			}
 		}()
 		// 中间件函数
 		c.Next()
 	}
-}
\ No newline at end of file
+}
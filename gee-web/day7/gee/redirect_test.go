@@ -0,0 +1,85 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirect(t *testing.T) {
+	r := New()
+	r.GET("/old", func(c *Context) {
+		c.Redirect(http.StatusMovedPermanently, "/new")
+	})
+	r.POST("/login", func(c *Context) {
+		c.Redirect(http.StatusSeeOther, "home")
+	})
+	r.GET("/bad", func(c *Context) {
+		defer func() {
+			if recover() == nil {
+				t.Error("expect panic for status 200")
+			}
+		}()
+		c.Redirect(http.StatusOK, "/new")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/old", nil))
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/new" {
+		t.Fatalf("expect 301 to /new, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/login", nil))
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/home" {
+		t.Fatalf("expect 303 to /home, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/bad", nil))
+}
+
+func TestHandleContext(t *testing.T) {
+	r := New()
+	var seen []string
+	r.Use(func(c *Context) {
+		seen = append(seen, c.Path)
+		c.Next()
+	})
+	r.GET("/old/:id", func(c *Context) {
+		c.Set("rewritten", true)
+		c.Req.URL.Path = "/new/" + c.Param("id")
+		r.HandleContext(c)
+	})
+	r.GET("/new/:id", func(c *Context) {
+		c.String(http.StatusOK, "new %s %v", c.Param("id"), c.MustGet("rewritten"))
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/old/1", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "new 1 true" {
+		t.Fatalf("expect rewritten response, got %d %q", w.Code, w.Body.String())
+	}
+	if len(seen) != 2 || seen[0] != "/old/1" || seen[1] != "/new/1" {
+		t.Fatalf("middlewares should run again for the new path, got %v", seen)
+	}
+}
+
+func TestHandleContextShorterChain(t *testing.T) {
+	r := New()
+	old := r.Group("/old")
+	// 原来的调用链比新的长, 并且中间件没有调用Next
+	old.Use(func(c *Context) {}, func(c *Context) {})
+	old.GET("/page", func(c *Context) {
+		c.Req.URL.Path = "/new"
+		r.HandleContext(c)
+	})
+	r.GET("/new", func(c *Context) {
+		c.String(http.StatusOK, "new")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/old/page", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "new" {
+		t.Fatalf("expect rewritten response, got %d %q", w.Code, w.Body.String())
+	}
+}
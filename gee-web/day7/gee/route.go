@@ -0,0 +1,111 @@
+// 路由的文档信息, 注册路由时通过链式调用添加, 用来生成OpenAPI文档
+package gee
+
+import "net/http"
+
+// 一个注册过的路由
+//
+//	r.POST("/users", createUser).
+//		Summary("创建用户").
+//		Tags("user").
+//		Request(CreateUserReq{}).
+//		Response(http.StatusCreated, User{})
+type Route struct {
+	Method string
+	// 完整的路由规则, 包含路由组的前缀, 例如 /v1/users/:id
+	Path string
+	// 路由组通过Host指定的host规则, 没有时为空
+	Host string
+
+	summary     string
+	description string
+	operationID string
+	tags        []string
+	request     interface{}
+	responses   []routeResponse
+	params      []routeParam
+	deprecated  bool
+	hidden      bool
+}
+
+type routeResponse struct {
+	code int
+	body interface{}
+}
+
+type routeParam struct {
+	in          string
+	name        string
+	description string
+}
+
+// 简短的说明
+func (r *Route) Summary(summary string) *Route {
+	r.summary = summary
+	return r
+}
+
+// 详细的说明, 可以使用markdown
+func (r *Route) Description(description string) *Route {
+	r.description = description
+	return r
+}
+
+// 唯一的操作名, 生成客户端代码时用作函数名
+func (r *Route) OperationID(id string) *Route {
+	r.operationID = id
+	return r
+}
+
+func (r *Route) Tags(tags ...string) *Route {
+	r.tags = append(r.tags, tags...)
+	return r
+}
+
+// 请求的结构体, 例如 CreateUserReq{}
+// GET/HEAD/DELETE请求时带有form标签的字段作为query参数, 其他请求作为JSON请求体
+func (r *Route) Request(v interface{}) *Route {
+	r.request = v
+	return r
+}
+
+// 某个状态码的响应结构体, v为nil表示没有响应体
+func (r *Route) Response(code int, v interface{}) *Route {
+	r.responses = append(r.responses, routeResponse{code: code, body: v})
+	return r
+}
+
+// 添加参数说明, in可以是 path, query, header, cookie
+// 路由规则中的 :name 和 *name 会自动作为path参数, 这里可以为它们补充说明
+func (r *Route) Param(in string, name string, description string) *Route {
+	r.params = append(r.params, routeParam{in: in, name: name, description: description})
+	return r
+}
+
+// 标记为已废弃
+func (r *Route) Deprecated() *Route {
+	r.deprecated = true
+	return r
+}
+
+// 不出现在文档里
+func (r *Route) Hidden() *Route {
+	r.hidden = true
+	return r
+}
+
+// 是否有请求体, 没有请求体时请求结构体作为query参数
+func (r *Route) hasBody() bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// 注册过的所有路由, 按注册顺序排列
+func (engine *Engine) Routes() []*Route {
+	routes := make([]*Route, len(engine.routes))
+	copy(routes, engine.routes)
+	return routes
+}
@@ -1,7 +1,6 @@
 package gee
 
 import (
-	"fmt"
 	"net/http"
 	"strings"
 )
@@ -12,6 +11,14 @@ type router struct {
 	handlers map[string] HandlerFunc
 	// 存放每种请求方式的根节点
 	root map[string] *node
+	// 指定了host的路由, 每个host有自己的路由树
+	hosts []*hostRouter
+}
+
+// 一个host对应的路由
+type hostRouter struct {
+	host *hostPattern
+	routes *router
 }
 
 // 构造函数
@@ -53,9 +60,53 @@ func (r *router) addRoute(method string, pattern string, handler HandlerFunc) {
 	r.root[method].insert(pattern, parts, 0)
 	// 下面的逻辑不用变 
 	key := method + "-" + pattern
+	if _, ok := r.handlers[key]; ok {
+		warnPrint("route %s %s is registered twice, the previous handler is overwritten", method, pattern)
+	}
+	debugPrint("%-6s %s", method, pattern)
 	r.handlers[key] = handler
 }
 
+// 添加只匹配某个host的路由
+func (r *router) addHostRoute(host *hostPattern, method string, pattern string, handler HandlerFunc) {
+	var hr *hostRouter
+	for _, h := range r.hosts {
+		if h.host.pattern == host.pattern {
+			hr = h
+			break
+		}
+	}
+	if hr == nil {
+		hr = &hostRouter{host: host, routes: newRouter()}
+		// 完全匹配的host放在前面, 优先匹配
+		if host.wildcard {
+			r.hosts = append(r.hosts, hr)
+		} else {
+			r.hosts = append([]*hostRouter{hr}, r.hosts...)
+		}
+	}
+	debugPrint("host %s:", host.pattern)
+	hr.routes.addRoute(method, pattern, handler)
+}
+
+// 查找请求的host和路径对应的host路由, 完全匹配的host优先
+// 都不匹配时返回nil, 使用没有指定host的路由
+func (r *router) matchHost(host string, method string, path string) *hostRouter {
+	if len(r.hosts) == 0 {
+		return nil
+	}
+	host = requestHost(host)
+	for _, hr := range r.hosts {
+		if _, ok := hr.host.match(host); !ok {
+			continue
+		}
+		if n, _ := hr.routes.getRoute(method, path); n != nil {
+			return hr
+		}
+	}
+	return nil
+}
+
 // 查询路由
 // path是用户传入的真实URL
 func (r *router) getRoute(method string, path string) (*node, map[string]string) {
@@ -92,17 +143,33 @@ func (r *router) getRoute(method string, path string) (*node, map[string]string)
 // 使用中间件后，由于中间件函数全部存储在c.handlers列表里面
 // 为了更好的进行执行，我们就使用c.Next()函数来遍历执行列表里面的函数
 // 此时，应该将业务逻辑函数append添加在c.handlers里面
-func (r *router) handle(c *Context) {
+// hr是matchHost的结果, 为nil时使用没有指定host的路由
+func (r *router) handle(c *Context, hr *hostRouter) {
+	routes := r
+	if hr != nil {
+		routes = hr.routes
+	}
 	// 查询params
-	n, params := r.getRoute(c.Method, c.Path)
+	n, params := routes.getRoute(c.Method, c.Path)
 	if n != nil{
+		if hr != nil {
+			// host中的参数, 和路径参数重名时以路径参数为准
+			hostParams, _ := hr.host.match(requestHost(c.Req.Host))
+			for k, v := range hostParams {
+				if _, ok := params[k]; !ok {
+					params[k] = v
+				}
+			}
+		}
 		c.Params = params
 		// 构造key
 		// 不去直接使用r.handlers判断是否存在是因为这次存在动态路径，所以要使用前缀树的搜索方法去匹配
+		c.fullPath = n.pattern
 		key := c.Method + "-" + n.pattern
-		fmt.Println(c.Path, n.pattern)
-		c.handlers = append(c.handlers, r.handlers[key])
+		debugPrint("%s matched %s", c.Path, n.pattern)
+		c.handlers = append(c.handlers, routes.handlers[key])
 	} else {
+		c.fullPath = ""
 		// 找不到对应路径
 		// 也需要将找不到路径的函数添加进来
 		c.handlers = append(c.handlers, func(ctx *Context) {
@@ -0,0 +1,147 @@
+// 安全相关的响应头中间件
+package gee
+
+import (
+	"encoding/base64"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// 本次请求的CSP nonce存放在上下文中的key
+const CSPNonceKey = "gee/csp-nonce"
+
+type SecureConfig struct {
+	// Strict-Transport-Security的max-age, 单位秒, 0表示不设置
+	// 只对https请求生效
+	HSTSMaxAge            int
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	// Content-Security-Policy, 其中的 {nonce} 会被替换为本次请求的nonce
+	// 例如 "script-src 'self' 'nonce-{nonce}'"
+	ContentSecurityPolicy string
+	// X-Frame-Options, 例如 DENY / SAMEORIGIN
+	FrameOptions string
+	// 设置 X-Content-Type-Options: nosniff
+	ContentTypeNosniff bool
+	// Referrer-Policy, 例如 strict-origin-when-cross-origin
+	ReferrerPolicy string
+	// Permissions-Policy, 例如 "geolocation=(), camera=()"
+	PermissionsPolicy string
+	// http请求重定向到https
+	SSLRedirect bool
+	// 重定向时使用的host, 为空时使用请求的host
+	SSLHost string
+	// 允许的host, 为空表示不限制
+	AllowedHosts []string
+	// 部署在代理后面时, 通过这些请求头判断是否是https, 例如 X-Forwarded-Proto: https
+	SSLProxyHeaders map[string]string
+}
+
+// 推荐的默认配置
+func DefaultSecureConfig() SecureConfig {
+	return SecureConfig{
+		HSTSMaxAge:            31536000,
+		HSTSIncludeSubdomains: true,
+		ContentSecurityPolicy: "default-src 'self'",
+		FrameOptions:          "DENY",
+		ContentTypeNosniff:    true,
+		ReferrerPolicy:        "strict-origin-when-cross-origin",
+	}
+}
+
+// 安全响应头中间件, 可以给不同的路由组使用不同的配置
+func Secure(config SecureConfig) HandlerFunc {
+	hsts := ""
+	if config.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(config.HSTSMaxAge)
+		if config.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if config.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+	useNonce := strings.Contains(config.ContentSecurityPolicy, "{nonce}")
+
+	return func(c *Context) {
+		if len(config.AllowedHosts) > 0 && !config.allowedHost(c.Req.Host) {
+			c.Fail(http.StatusForbidden, "host not allowed")
+			return
+		}
+		isSSL := config.isSSL(c.Req)
+		if config.SSLRedirect && !isSSL {
+			u := *c.Req.URL
+			u.Scheme = "https"
+			u.Host = c.Req.Host
+			if config.SSLHost != "" {
+				u.Host = config.SSLHost
+			}
+			code := http.StatusMovedPermanently
+			if c.Method != http.MethodGet && c.Method != http.MethodHead {
+				// 保证重定向后请求方法和内容不变
+				code = http.StatusPermanentRedirect
+			}
+			c.SetHeader("Location", u.String())
+			c.AbortWithStatus(code)
+			return
+		}
+
+		header := c.Writer.Header()
+		if hsts != "" && isSSL {
+			header.Set("Strict-Transport-Security", hsts)
+		}
+		if config.ContentSecurityPolicy != "" {
+			csp := config.ContentSecurityPolicy
+			if useNonce {
+				nonce := base64.StdEncoding.EncodeToString(randomBytes(16))
+				c.Set(CSPNonceKey, nonce)
+				csp = strings.ReplaceAll(csp, "{nonce}", nonce)
+			}
+			header.Set("Content-Security-Policy", csp)
+		}
+		if config.FrameOptions != "" {
+			header.Set("X-Frame-Options", config.FrameOptions)
+		}
+		if config.ContentTypeNosniff {
+			header.Set("X-Content-Type-Options", "nosniff")
+		}
+		if config.ReferrerPolicy != "" {
+			header.Set("Referrer-Policy", config.ReferrerPolicy)
+		}
+		if config.PermissionsPolicy != "" {
+			header.Set("Permissions-Policy", config.PermissionsPolicy)
+		}
+		c.Next()
+	}
+}
+
+// 本次请求的CSP nonce, 传给模板后用在 <script nonce="{{.nonce}}"> 上
+func (c *Context) CSPNonce() string {
+	return c.GetString(CSPNonceKey)
+}
+
+func (config *SecureConfig) allowedHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	for _, allowed := range config.AllowedHosts {
+		if strings.EqualFold(allowed, host) {
+			return true
+		}
+	}
+	return false
+}
+
+func (config *SecureConfig) isSSL(req *http.Request) bool {
+	if req.TLS != nil {
+		return true
+	}
+	for k, v := range config.SSLProxyHeaders {
+		if strings.EqualFold(req.Header.Get(k), v) {
+			return true
+		}
+	}
+	return false
+}
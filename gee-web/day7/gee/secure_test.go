@@ -0,0 +1,121 @@
+package gee
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSecureHSTS(t *testing.T) {
+	config := DefaultSecureConfig()
+	config.SSLProxyHeaders = map[string]string{"X-Forwarded-Proto": "https"}
+	r := New()
+	r.Use(Secure(config))
+	r.GET("/", func(c *Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	tests := []struct {
+		name  string
+		tls   bool
+		proto string
+		hsts  bool
+	}{
+		{"http", false, "", false},
+		{"tls", true, "", true},
+		{"proxy header", false, "HTTPS", true},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tt.tls {
+			req.TLS = &tls.ConnectionState{}
+		}
+		if tt.proto != "" {
+			req.Header.Set("X-Forwarded-Proto", tt.proto)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		hsts := w.Header().Get("Strict-Transport-Security")
+		if (hsts != "") != tt.hsts {
+			t.Fatalf("%s: unexpected HSTS %q", tt.name, hsts)
+		}
+		if tt.hsts && hsts != "max-age=31536000; includeSubDomains" {
+			t.Fatalf("%s: unexpected HSTS %q", tt.name, hsts)
+		}
+		if w.Header().Get("X-Frame-Options") != "DENY" || w.Header().Get("X-Content-Type-Options") != "nosniff" {
+			t.Fatalf("%s: missing default headers: %v", tt.name, w.Header())
+		}
+	}
+}
+
+func TestSecureCSPNonce(t *testing.T) {
+	r := New()
+	r.Use(Secure(SecureConfig{ContentSecurityPolicy: "script-src 'self' 'nonce-{nonce}'"}))
+	r.GET("/", func(c *Context) {
+		c.String(http.StatusOK, "%s", c.CSPNonce())
+	})
+
+	var nonces []string
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		nonce := w.Body.String()
+		if nonce == "" || w.Header().Get("Content-Security-Policy") != "script-src 'self' 'nonce-"+nonce+"'" {
+			t.Fatalf("nonce %q not substituted: %q", nonce, w.Header().Get("Content-Security-Policy"))
+		}
+		nonces = append(nonces, nonce)
+	}
+	if nonces[0] == nonces[1] {
+		t.Fatal("each request should get a new nonce")
+	}
+}
+
+func TestSecureSSLRedirect(t *testing.T) {
+	r := New()
+	r.Use(Secure(SecureConfig{SSLRedirect: true}))
+	r.GET("/page", func(c *Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	r.POST("/page", func(c *Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	for method, code := range map[string]int{"GET": http.StatusMovedPermanently, "POST": http.StatusPermanentRedirect} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, "http://example.com/page?a=1", nil))
+		if w.Code != code || w.Header().Get("Location") != "https://example.com/page?a=1" {
+			t.Fatalf("%s: expect %d to https, got %d %q", method, code, w.Code, w.Header().Get("Location"))
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/page", nil)
+	req.TLS = &tls.ConnectionState{}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("https request should not be redirected, got %d", w.Code)
+	}
+}
+
+func TestSecureAllowedHosts(t *testing.T) {
+	r := New()
+	r.Use(Secure(SecureConfig{AllowedHosts: []string{"example.com"}}))
+	r.GET("/", func(c *Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	for host, code := range map[string]int{"example.com": http.StatusOK, "EXAMPLE.com:8080": http.StatusOK, "evil.com": http.StatusForbidden} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = host
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != code {
+			t.Fatalf("%s: expect %d, got %d", host, code, w.Code)
+		}
+		if code == http.StatusForbidden && strings.Contains(w.Body.String(), "ok") {
+			t.Fatalf("%s: handler should not run", host)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+// 服务器的启动和优雅退出
+package gee
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// http.Server的配置
+type ServerConfig struct {
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	// 优雅退出时最多等待多久, 超时后强制关闭所有连接
+	ShutdownTimeout time.Duration
+	// 不使用TLS时也支持HTTP/2 (h2c, 需要客户端直接以HTTP/2发起连接)
+	H2C bool
+}
+
+// 默认配置, 防止慢客户端一直占用连接
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		MaxHeaderBytes:    http.DefaultMaxHeaderBytes,
+		ShutdownTimeout:   10 * time.Second,
+	}
+}
+
+// 注册服务器开始监听后执行的函数
+func (engine *Engine) OnStart(fn func()) {
+	engine.onStart = append(engine.onStart, fn)
+}
+
+// 注册服务器退出时执行的函数, 在所有请求处理完之后执行
+func (engine *Engine) OnShutdown(fn func()) {
+	engine.onShutdown = append(engine.onShutdown, fn)
+}
+
+// 启动服务器, ctx结束后优雅退出
+// 正常退出时返回nil
+func (engine *Engine) RunWithContext(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return engine.serve(ctx, ln, func(srv *http.Server) error {
+		return srv.Serve(ln)
+	})
+}
+
+// 优雅退出所有正在运行的服务器: 停止接受新连接, 等待正在处理的请求结束
+// ctx结束时还没处理完的连接会被强制关闭
+func (engine *Engine) Shutdown(ctx context.Context) error {
+	engine.serversMu.Lock()
+	servers := engine.servers
+	engine.servers = nil
+	engine.serversMu.Unlock()
+	if len(servers) == 0 {
+		return nil
+	}
+
+	var firstErr error
+	for _, rs := range servers {
+		if err := rs.srv.Shutdown(ctx); err != nil {
+			rs.srv.Close()
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	for _, fn := range engine.onShutdown {
+		fn()
+	}
+	// 请求都处理完, OnShutdown也执行完之后, Run才能返回
+	for _, rs := range servers {
+		close(rs.done)
+	}
+	return firstErr
+}
+
+// 正在运行的服务器, done在Shutdown结束后关闭
+type runningServer struct {
+	srv  *http.Server
+	done chan struct{}
+}
+
+func (engine *Engine) newServer() *http.Server {
+	config := engine.Server
+	srv := &http.Server{
+		Handler:           engine,
+		ReadTimeout:       config.ReadTimeout,
+		ReadHeaderTimeout: config.ReadHeaderTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+		MaxHeaderBytes:    config.MaxHeaderBytes,
+	}
+	if config.H2C {
+		// 标准库已经支持h2c, 不需要再依赖 golang.org/x/net/http2/h2c
+		srv.Protocols = new(http.Protocols)
+		srv.Protocols.SetHTTP1(true)
+		srv.Protocols.SetHTTP2(true)
+		srv.Protocols.SetUnencryptedHTTP2(true)
+	}
+	return srv
+}
+
+// 所有启动方式共用的逻辑: 创建http.Server, 执行OnStart, ctx结束后优雅退出
+func (engine *Engine) serve(ctx context.Context, ln net.Listener, serveFn func(srv *http.Server) error) error {
+	srv := engine.newServer()
+	rs := &runningServer{srv: srv, done: make(chan struct{})}
+	engine.serversMu.Lock()
+	engine.servers = append(engine.servers, rs)
+	engine.serversMu.Unlock()
+
+	debugPrint("Listening and serving HTTP on %s", ln.Addr())
+	if len(engine.router.handlers) == 0 && len(engine.router.hosts) == 0 {
+		warnPrint("no routes are registered")
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- serveFn(srv)
+	}()
+	for _, fn := range engine.onStart {
+		fn()
+	}
+
+	select {
+	case err := <-errCh:
+		// 被其他地方调用了Shutdown, Serve会立刻返回, 要等正在处理的请求结束
+		if err == http.ErrServerClosed {
+			<-rs.done
+			return nil
+		}
+		engine.removeServer(rs)
+		ln.Close()
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), engine.Server.ShutdownTimeout)
+		defer cancel()
+		err := engine.Shutdown(shutdownCtx)
+		<-errCh
+		return err
+	}
+}
+
+func (engine *Engine) removeServer(rs *runningServer) {
+	engine.serversMu.Lock()
+	defer engine.serversMu.Unlock()
+	for i, s := range engine.servers {
+		if s == rs {
+			engine.servers = append(engine.servers[:i], engine.servers[i+1:]...)
+			return
+		}
+	}
+}
+
+// 使用https启动, 证书文件更新后会自动重新加载, 不需要重启
+func (engine *Engine) RunTLS(addr, certFile, keyFile string) error {
+	ctx, stop := signalContext()
+	defer stop()
+	return engine.RunTLSWithContext(ctx, addr, certFile, keyFile)
+}
+
+func (engine *Engine) RunTLSWithContext(ctx context.Context, addr, certFile, keyFile string) error {
+	reloader, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return engine.serve(ctx, ln, func(srv *http.Server) error {
+		srv.TLSConfig = &tls.Config{GetCertificate: reloader.GetCertificate}
+		return srv.ServeTLS(ln, "", "")
+	})
+}
+
+// 监听unix socket, perm为socket文件的权限, 例如0660
+// 退出时会删除socket文件
+func (engine *Engine) RunUnix(socketPath string, perm os.FileMode) error {
+	ctx, stop := signalContext()
+	defer stop()
+	return engine.RunUnixWithContext(ctx, socketPath, perm)
+}
+
+func (engine *Engine) RunUnixWithContext(ctx context.Context, socketPath string, perm os.FileMode) error {
+	// 上次异常退出时遗留的socket文件
+	if info, err := os.Stat(socketPath); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return fmt.Errorf("gee: %s exists and is not a socket", socketPath)
+		}
+		os.Remove(socketPath)
+	}
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(socketPath)
+	if err := os.Chmod(socketPath, perm); err != nil {
+		ln.Close()
+		return err
+	}
+	return engine.serve(ctx, ln, func(srv *http.Server) error {
+		return srv.Serve(ln)
+	})
+}
+
+// 使用已有的listener启动, 例如systemd socket activation传过来的listener
+func (engine *Engine) RunListener(ln net.Listener) error {
+	ctx, stop := signalContext()
+	defer stop()
+	return engine.RunListenerWithContext(ctx, ln)
+}
+
+func (engine *Engine) RunListenerWithContext(ctx context.Context, ln net.Listener) error {
+	return engine.serve(ctx, ln, func(srv *http.Server) error {
+		return srv.Serve(ln)
+	})
+}
+
+// 使用继承来的文件描述符启动, systemd socket activation时第一个fd是3
+func (engine *Engine) RunFd(fd int) error {
+	f := os.NewFile(uintptr(fd), fmt.Sprintf("fd@%d", fd))
+	if f == nil {
+		return fmt.Errorf("gee: invalid fd %d", fd)
+	}
+	ln, err := net.FileListener(f)
+	// FileListener会复制一份fd, 原来的可以关闭
+	f.Close()
+	if err != nil {
+		return err
+	}
+	return engine.RunListener(ln)
+}
+
+// 证书热加载, 文件的修改时间变化时重新读取
+type certReloader struct {
+	certFile, keyFile string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert = &cert
+	r.modTime = r.latestModTime()
+	return nil
+}
+
+// 证书和私钥里较新的修改时间
+func (r *certReloader) latestModTime() time.Time {
+	var latest time.Time
+	for _, file := range []string{r.certFile, r.keyFile} {
+		if info, err := os.Stat(file); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// 每次握手时调用, 最多每秒检查一次文件
+// 新证书加载失败时继续使用旧的证书
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if now.Sub(r.lastCheck) >= time.Second {
+		r.lastCheck = now
+		if r.latestModTime().After(r.modTime) {
+			if err := r.reload(); err != nil {
+				log.Printf("gee: reload certificate failed: %v", err)
+			}
+		}
+	}
+	return r.cert, nil
+}
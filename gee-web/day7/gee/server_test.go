@@ -0,0 +1,161 @@
+package gee
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunWithContextLifecycle(t *testing.T) {
+	r := New()
+	started := make(chan struct{})
+	stopped := false
+	r.OnStart(func() { close(started) })
+	r.OnShutdown(func() { stopped = true })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- r.RunWithContext(ctx, "127.0.0.1:0")
+	}()
+	<-started
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil || !stopped {
+			t.Fatalf("expect graceful shutdown, got err=%v stopped=%v", err, stopped)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("server did not shut down")
+	}
+}
+
+func TestShutdownWaitsForRequests(t *testing.T) {
+	r := New()
+	inHandler := make(chan struct{})
+	release := make(chan struct{})
+	r.GET("/slow", func(c *Context) {
+		close(inHandler)
+		<-release
+		c.String(http.StatusOK, "done")
+	})
+	stopped := make(chan struct{})
+	r.OnShutdown(func() { close(stopped) })
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runDone := make(chan error, 1)
+	go func() {
+		runDone <- r.RunListenerWithContext(context.Background(), ln)
+	}()
+	respDone := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/slow")
+		if err != nil {
+			respDone <- err.Error()
+			return
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		respDone <- string(body)
+	}()
+	<-inHandler
+
+	// 在其他goroutine里调用Shutdown, Run要等请求处理完才返回
+	go r.Shutdown(context.Background())
+	select {
+	case <-runDone:
+		t.Fatal("Run returned while a request is still in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	if err := <-runDone; err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-stopped:
+	default:
+		t.Fatal("OnShutdown should run before Run returns")
+	}
+	if body := <-respDone; body != "done" {
+		t.Fatalf("expect the in-flight request to finish, got %q", body)
+	}
+}
+
+func TestRunUnix(t *testing.T) {
+	r := New()
+	r.GET("/ping", func(c *Context) {
+		c.String(http.StatusOK, "pong")
+	})
+	socket := filepath.Join(t.TempDir(), "gee.sock")
+	started := make(chan struct{})
+	r.OnStart(func() { close(started) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- r.RunUnixWithContext(ctx, socket, 0600)
+	}()
+	<-started
+	if info, err := os.Stat(socket); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("expect socket with mode 0600, got %v %v", info, err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return net.Dial("unix", socket)
+		},
+	}}
+	resp, err := client.Get("http://gee/ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "pong" {
+		t.Fatalf("expect pong, got %q", body)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Fatal("socket file should be removed")
+	}
+}
+
+func TestH2C(t *testing.T) {
+	r := New()
+	r.Server.H2C = true
+	r.GET("/proto", func(c *Context) {
+		c.String(http.StatusOK, "%s", c.Req.Proto)
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.RunListenerWithContext(ctx, ln)
+
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+	resp, err := client.Get("http://" + ln.Addr().String() + "/proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "HTTP/2.0" {
+		t.Fatalf("expect HTTP/2.0, got %q", body)
+	}
+}
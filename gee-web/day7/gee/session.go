@@ -0,0 +1,452 @@
+// session管理, 支持把数据签名加密后存在cookie里, 或者只在cookie里存放session id
+package gee
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// session存放在上下文中的key
+const SessionKey = "gee/session"
+
+// flash消息存放在session中的key
+const flashKey = "_flash"
+
+var (
+	ErrSessionNotFound = errors.New("gee: session not found")
+	ErrSessionInvalid  = errors.New("gee: session cookie is invalid")
+)
+
+func init() {
+	// flash消息以 []interface{} 的形式存在session里
+	gob.Register([]interface{}{})
+}
+
+// session cookie的属性
+type SessionOptions struct {
+	Path   string
+	Domain string
+	// 单位秒, 小于0表示删除, 等于0表示浏览器关闭后失效
+	MaxAge   int
+	Secure   bool
+	HttpOnly bool
+	SameSite http.SameSite
+}
+
+// 默认30天, 只允许http访问, SameSite=Lax
+func DefaultSessionOptions() SessionOptions {
+	return SessionOptions{
+		Path:     "/",
+		MaxAge:   86400 * 30,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// session的存储方式
+type SessionStore interface {
+	// 从请求中加载session, 不存在或者无效时返回一个新的session
+	Load(c *Context, name string) (*Session, error)
+	// 保存session并写入cookie, 必须在写入响应之前调用
+	Save(c *Context, s *Session) error
+}
+
+type Session struct {
+	// 服务端存储时的session id, cookie存储时为空
+	ID      string
+	Name    string
+	Values  map[string]interface{}
+	Options SessionOptions
+	// 是否是这次请求新建的
+	IsNew bool
+
+	store SessionStore
+	c     *Context
+	// Regenerate之前的id, 保存时会被删除
+	oldID string
+}
+
+// session中间件, 之后可以通过 c.Session() 获取
+func Sessions(name string, store SessionStore) HandlerFunc {
+	return func(c *Context) {
+		s, err := store.Load(c, name)
+		if err != nil {
+			c.Fail(http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+		c.Set(SessionKey, s)
+		c.Next()
+	}
+}
+
+// 获取Sessions中间件加载的session
+func (c *Context) Session() *Session {
+	return c.MustGet(SessionKey).(*Session)
+}
+
+func newSession(c *Context, store SessionStore, name string, options SessionOptions) *Session {
+	return &Session{
+		Name:    name,
+		Values:  make(map[string]interface{}),
+		Options: options,
+		IsNew:   true,
+		store:   store,
+		c:       c,
+	}
+}
+
+func (s *Session) Get(key string) interface{} {
+	return s.Values[key]
+}
+
+func (s *Session) Set(key string, val interface{}) {
+	s.Values[key] = val
+}
+
+func (s *Session) Delete(key string) {
+	delete(s.Values, key)
+}
+
+// 清空所有数据
+func (s *Session) Clear() {
+	s.Values = make(map[string]interface{})
+}
+
+// 添加一条flash消息, 读取一次之后就会被删除
+func (s *Session) AddFlash(val interface{}) {
+	flashes, _ := s.Values[flashKey].([]interface{})
+	s.Values[flashKey] = append(flashes, val)
+}
+
+// 取出并删除所有flash消息, 需要Save之后才会生效
+func (s *Session) Flashes() []interface{} {
+	flashes, _ := s.Values[flashKey].([]interface{})
+	delete(s.Values, flashKey)
+	return flashes
+}
+
+// 更换session id, 登录成功后调用, 防止会话固定攻击
+func (s *Session) Regenerate() {
+	if s.oldID == "" {
+		s.oldID = s.ID
+	}
+	s.ID = ""
+	s.IsNew = true
+}
+
+// 销毁session, 同时删除cookie
+func (s *Session) Destroy() error {
+	s.Clear()
+	s.Options.MaxAge = -1
+	return s.Save()
+}
+
+// 保存session, 必须在写入响应之前调用
+func (s *Session) Save() error {
+	return s.store.Save(s.c, s)
+}
+
+func (s *Session) writeCookie(value string) {
+	cookie := &http.Cookie{
+		Name:     s.Name,
+		Value:    value,
+		Path:     s.Options.Path,
+		Domain:   s.Options.Domain,
+		MaxAge:   s.Options.MaxAge,
+		Secure:   s.Options.Secure,
+		HttpOnly: s.Options.HttpOnly,
+		SameSite: s.Options.SameSite,
+	}
+	if s.Options.MaxAge > 0 {
+		cookie.Expires = time.Now().Add(time.Duration(s.Options.MaxAge) * time.Second)
+	} else if s.Options.MaxAge < 0 {
+		cookie.Value = ""
+		cookie.Expires = time.Unix(1, 0)
+	}
+	s.c.SetCookie(cookie)
+}
+
+// 把session数据签名加密后存放在cookie里
+type CookieStore struct {
+	Options SessionOptions
+	codec   *cookieCodec
+}
+
+// hashKey用来签名, 建议32或64字节
+// blockKey用来加密, 必须是16/24/32字节, 为nil时只签名不加密
+func NewCookieStore(hashKey, blockKey []byte) *CookieStore {
+	return &CookieStore{Options: DefaultSessionOptions(), codec: newCookieCodec(hashKey, blockKey)}
+}
+
+func (st *CookieStore) Load(c *Context, name string) (*Session, error) {
+	s := newSession(c, st, name, st.Options)
+	value, err := c.Cookie(name)
+	if err != nil {
+		return s, nil
+	}
+	data, err := st.codec.decode(name, value, st.Options.MaxAge)
+	if err != nil {
+		// 被篡改或者过期的cookie, 直接当作新的session
+		return s, nil
+	}
+	if err := decodeValues(data, &s.Values); err != nil {
+		return s, nil
+	}
+	s.IsNew = false
+	return s, nil
+}
+
+func (st *CookieStore) Save(c *Context, s *Session) error {
+	if s.Options.MaxAge < 0 {
+		s.writeCookie("")
+		return nil
+	}
+	data, err := encodeValues(s.Values)
+	if err != nil {
+		return err
+	}
+	value, err := st.codec.encode(s.Name, data)
+	if err != nil {
+		return err
+	}
+	s.writeCookie(value)
+	return nil
+}
+
+// 服务端存储session数据的后端, 可以实现这个接口接入redis等外部存储
+type SessionBackend interface {
+	// 不存在时返回 ErrSessionNotFound
+	Load(id string) ([]byte, error)
+	// ttl为0表示不过期
+	Save(id string, data []byte, ttl time.Duration) error
+	Delete(id string) error
+}
+
+// cookie里只存放签名后的session id, 数据保存在服务端
+type ServerStore struct {
+	Options SessionOptions
+	backend SessionBackend
+	codec   *cookieCodec
+}
+
+// hashKey用来给cookie中的session id签名
+func NewServerStore(backend SessionBackend, hashKey []byte) *ServerStore {
+	return &ServerStore{Options: DefaultSessionOptions(), backend: backend, codec: newCookieCodec(hashKey, nil)}
+}
+
+// 数据存放在内存中的ServerStore, 适合单机部署
+func NewMemoryStore(hashKey []byte) *ServerStore {
+	return NewServerStore(NewMemorySessionBackend(), hashKey)
+}
+
+func (st *ServerStore) Load(c *Context, name string) (*Session, error) {
+	s := newSession(c, st, name, st.Options)
+	value, err := c.Cookie(name)
+	if err != nil {
+		return s, nil
+	}
+	id, err := st.codec.decode(name, value, st.Options.MaxAge)
+	if err != nil {
+		return s, nil
+	}
+	data, err := st.backend.Load(string(id))
+	if err == ErrSessionNotFound {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := decodeValues(data, &s.Values); err != nil {
+		return s, nil
+	}
+	s.ID = string(id)
+	s.IsNew = false
+	return s, nil
+}
+
+func (st *ServerStore) Save(c *Context, s *Session) error {
+	if s.oldID != "" {
+		if err := st.backend.Delete(s.oldID); err != nil {
+			return err
+		}
+		s.oldID = ""
+	}
+	if s.Options.MaxAge < 0 {
+		if s.ID != "" {
+			if err := st.backend.Delete(s.ID); err != nil {
+				return err
+			}
+		}
+		s.writeCookie("")
+		return nil
+	}
+	if s.ID == "" {
+		s.ID = base64.RawURLEncoding.EncodeToString(randomBytes(32))
+	}
+	data, err := encodeValues(s.Values)
+	if err != nil {
+		return err
+	}
+	if err := st.backend.Save(s.ID, data, time.Duration(s.Options.MaxAge)*time.Second); err != nil {
+		return err
+	}
+	value, err := st.codec.encode(s.Name, []byte(s.ID))
+	if err != nil {
+		return err
+	}
+	s.writeCookie(value)
+	return nil
+}
+
+type memorySession struct {
+	data    []byte
+	expires time.Time
+}
+
+// 内存中的SessionBackend, 过期的session在保存时顺带清理
+type MemorySessionBackend struct {
+	mu        sync.Mutex
+	sessions  map[string]memorySession
+	lastSweep time.Time
+}
+
+func NewMemorySessionBackend() *MemorySessionBackend {
+	return &MemorySessionBackend{sessions: make(map[string]memorySession)}
+}
+
+func (b *MemorySessionBackend) Load(id string) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.sessions[id]
+	if !ok || (!s.expires.IsZero() && time.Now().After(s.expires)) {
+		return nil, ErrSessionNotFound
+	}
+	return s.data, nil
+}
+
+func (b *MemorySessionBackend) Save(id string, data []byte, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	if now.Sub(b.lastSweep) > time.Minute {
+		b.lastSweep = now
+		for k, s := range b.sessions {
+			if !s.expires.IsZero() && now.After(s.expires) {
+				delete(b.sessions, k)
+			}
+		}
+	}
+	s := memorySession{data: data}
+	if ttl > 0 {
+		s.expires = now.Add(ttl)
+	}
+	b.sessions[id] = s
+	return nil
+}
+
+func (b *MemorySessionBackend) Delete(id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.sessions, id)
+	return nil
+}
+
+func encodeValues(values map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(values); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeValues(data []byte, values *map[string]interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(values)
+}
+
+// cookie值的编码: base64(时间戳 | 数据 | HMAC(name | 时间戳 | 数据))
+// 配置了blockKey时数据先用AES-GCM加密
+type cookieCodec struct {
+	hashKey []byte
+	aead    cipher.AEAD
+}
+
+func newCookieCodec(hashKey, blockKey []byte) *cookieCodec {
+	if len(hashKey) == 0 {
+		panic("gee: session hash key can not be empty")
+	}
+	codec := &cookieCodec{hashKey: hashKey}
+	if blockKey != nil {
+		block, err := aes.NewCipher(blockKey)
+		if err != nil {
+			panic("gee: invalid session block key: " + err.Error())
+		}
+		codec.aead, _ = cipher.NewGCM(block)
+	}
+	return codec
+}
+
+func (cc *cookieCodec) encode(name string, data []byte) (string, error) {
+	if cc.aead != nil {
+		nonce := randomBytes(cc.aead.NonceSize())
+		data = cc.aead.Seal(nonce, nonce, data, []byte(name))
+	}
+	buf := make([]byte, 8, 8+len(data)+sha256.Size)
+	binary.BigEndian.PutUint64(buf, uint64(time.Now().Unix()))
+	buf = append(buf, data...)
+	buf = append(buf, cc.mac(name, buf)...)
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// maxAge大于0时, 超过maxAge秒的cookie视为无效
+func (cc *cookieCodec) decode(name, value string, maxAge int) ([]byte, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(buf) < 8+sha256.Size {
+		return nil, ErrSessionInvalid
+	}
+	body, sum := buf[:len(buf)-sha256.Size], buf[len(buf)-sha256.Size:]
+	if !hmac.Equal(sum, cc.mac(name, body)) {
+		return nil, ErrSessionInvalid
+	}
+	ts := time.Unix(int64(binary.BigEndian.Uint64(body)), 0)
+	if maxAge > 0 && time.Since(ts) > time.Duration(maxAge)*time.Second {
+		return nil, ErrSessionInvalid
+	}
+	data := body[8:]
+	if cc.aead != nil {
+		n := cc.aead.NonceSize()
+		if len(data) < n {
+			return nil, ErrSessionInvalid
+		}
+		if data, err = cc.aead.Open(nil, data[:n], data[n:], []byte(name)); err != nil {
+			return nil, ErrSessionInvalid
+		}
+	}
+	return data, nil
+}
+
+func (cc *cookieCodec) mac(name string, body []byte) []byte {
+	h := hmac.New(sha256.New, cc.hashKey)
+	h.Write([]byte(name))
+	h.Write([]byte{'|'})
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -0,0 +1,67 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newSessionEngine(store SessionStore) *Engine {
+	r := New()
+	r.Use(Sessions("gee_session", store))
+	r.POST("/login", func(c *Context) {
+		s := c.Session()
+		s.Regenerate()
+		s.Set("user", "geektutu")
+		s.AddFlash("welcome")
+		s.Save()
+		c.String(http.StatusOK, "ok")
+	})
+	r.GET("/me", func(c *Context) {
+		s := c.Session()
+		flashes := s.Flashes()
+		s.Save()
+		c.String(http.StatusOK, "%v %v", s.Get("user"), flashes)
+	})
+	return r
+}
+
+func testSessionStore(t *testing.T, store SessionStore) {
+	r := newSessionEngine(store)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/login", nil))
+	cookie := w.Result().Cookies()[0]
+	if !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("unexpected cookie options: %+v", cookie)
+	}
+
+	get := func(ck *http.Cookie) (string, *http.Cookie) {
+		req := httptest.NewRequest("GET", "/me", nil)
+		req.AddCookie(ck)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Body.String(), w.Result().Cookies()[0]
+	}
+	body, next := get(cookie)
+	if body != "geektutu [welcome]" {
+		t.Fatalf("expect session with flash, got %q", body)
+	}
+	if body, _ = get(next); body != "geektutu []" {
+		t.Fatalf("flash should be consumed, got %q", body)
+	}
+
+	tampered := *cookie
+	tampered.Value = strings.ToUpper(cookie.Value[:4]) + cookie.Value[4:] + "x"
+	if body, _ = get(&tampered); body != "<nil> []" {
+		t.Fatalf("tampered cookie should be ignored, got %q", body)
+	}
+}
+
+func TestCookieStore(t *testing.T) {
+	testSessionStore(t, NewCookieStore([]byte("hash-key"), []byte("0123456789abcdef")))
+}
+
+func TestMemoryStore(t *testing.T) {
+	testSessionStore(t, NewMemoryStore([]byte("hash-key")))
+}
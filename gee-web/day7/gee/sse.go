@@ -0,0 +1,154 @@
+// Server-Sent Events 和流式响应
+package gee
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 一条SSE事件
+type SSEvent struct {
+	// 事件id, 客户端重连时通过 Last-Event-ID 请求头带回来
+	ID string
+	// 事件类型, 为空时客户端按 message 处理
+	Event string
+	// string和[]byte原样发送, 其他类型编码为JSON
+	Data interface{}
+	// 通知客户端断开后多久重连
+	Retry time.Duration
+}
+
+// 发送一条SSE事件并立即flush
+func (c *Context) SSEvent(event string, data interface{}) error {
+	return c.WriteSSE(SSEvent{Event: event, Data: data})
+}
+
+// 发送一条完整的SSE事件并立即flush
+func (c *Context) WriteSSE(ev SSEvent) error {
+	c.sseHeaders()
+	if err := writeSSE(c.Writer, ev); err != nil {
+		return err
+	}
+	return c.Flush()
+}
+
+// 客户端重连时带上的最后一个事件id, 用来从断开的地方继续推送
+func (c *Context) LastEventID() string {
+	return c.Req.Header.Get("Last-Event-ID")
+}
+
+// 把缓冲区里的数据立即发送给客户端
+func (c *Context) Flush() error {
+	return http.NewResponseController(c.Writer).Flush()
+}
+
+// 流式响应: 反复调用step, 每次调用后flush, step返回false时结束
+// 客户端断开时返回true
+func (c *Context) Stream(step func(w io.Writer) bool) bool {
+	ctx := c.Req.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return true
+		default:
+			keepOpen := step(c.Writer)
+			c.Flush()
+			if !keepOpen {
+				return false
+			}
+		}
+	}
+}
+
+// 把events里的事件推送给客户端, 直到events被关闭或者客户端断开
+// heartbeat大于0时, 空闲时定期发送注释行, 防止代理断开空闲连接
+// 客户端断开时返回true
+func (c *Context) SSEStream(events <-chan SSEvent, heartbeat time.Duration) bool {
+	c.sseHeaders()
+	c.Flush()
+	ctx := c.Req.Context()
+	var tick <-chan time.Time
+	if heartbeat > 0 {
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return true
+		case ev, ok := <-events:
+			if !ok {
+				return false
+			}
+			if err := c.WriteSSE(ev); err != nil {
+				return true
+			}
+		case <-tick:
+			if _, err := io.WriteString(c.Writer, ":heartbeat\n\n"); err != nil {
+				return true
+			}
+			if err := c.Flush(); err != nil {
+				return true
+			}
+		}
+	}
+}
+
+// 第一次发送前设置响应头
+func (c *Context) sseHeaders() {
+	header := c.Writer.Header()
+	if header.Get("Content-Type") == "text/event-stream" {
+		return
+	}
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// 关闭nginx的缓冲
+	header.Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+}
+
+// 按照SSE格式写入一条事件, 多行数据会拆成多个data字段
+func writeSSE(w io.Writer, ev SSEvent) error {
+	var b strings.Builder
+	if ev.ID != "" {
+		b.WriteString("id: " + sseEscape(ev.ID) + "\n")
+	}
+	if ev.Event != "" {
+		b.WriteString("event: " + sseEscape(ev.Event) + "\n")
+	}
+	if ev.Retry > 0 {
+		b.WriteString(fmt.Sprintf("retry: %d\n", ev.Retry.Milliseconds()))
+	}
+	var data string
+	switch d := ev.Data.(type) {
+	case nil:
+	case string:
+		data = d
+	case []byte:
+		data = string(d)
+	default:
+		buf, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+		data = string(buf)
+	}
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// id和event里不能有换行
+func sseEscape(s string) string {
+	return strings.NewReplacer("\n", "", "\r", "").Replace(s)
+}
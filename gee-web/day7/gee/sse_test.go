@@ -0,0 +1,32 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSSEStream(t *testing.T) {
+	r := New()
+	r.GET("/events", func(c *Context) {
+		events := make(chan SSEvent, 2)
+		events <- SSEvent{ID: c.LastEventID() + "1", Event: "tick", Data: "line1\nline2"}
+		events <- SSEvent{Data: H{"n": 2}, Retry: time.Second}
+		close(events)
+		c.SSEStream(events, time.Minute)
+	})
+
+	req := httptest.NewRequest("GET", "/events", nil)
+	req.Header.Set("Last-Event-ID", "4")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	want := "id: 41\nevent: tick\ndata: line1\ndata: line2\n\nretry: 1000\ndata: {\"n\":2}\n\n"
+	if w.Body.String() != want || w.Header().Get("Content-Type") != "text/event-stream" || !w.Flushed {
+		t.Fatalf("unexpected stream: %q %v", w.Body.String(), w.Header())
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("expect 200, got %d", w.Code)
+	}
+}
@@ -0,0 +1,167 @@
+// 静态文件服务
+package gee
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+type StaticConfig struct {
+	// 请求目录时返回的文件, 为空表示不返回
+	Index string
+	// 允许列出目录, 默认不允许
+	Browse bool
+	// 找不到文件时返回的文件, 用于单页应用, 例如 index.html
+	SPAFallback string
+	// Cache-Control响应头, 例如 "public, max-age=3600"
+	CacheControl string
+	// 生成ETag, 配合If-None-Match返回304
+	ETag bool
+	// 客户端支持gzip时, 优先返回同目录下的 .gz 文件
+	Precompressed bool
+}
+
+// 默认返回index.html, 不允许列出目录, 生成ETag
+func DefaultStaticConfig() StaticConfig {
+	return StaticConfig{Index: "index.html", ETag: true}
+}
+
+// 使用fs.FS提供静态文件, 可以配合go:embed使用
+func (rg *RouterGroup) StaticFS(relativePath string, fsys fs.FS) {
+	rg.StaticWithConfig(relativePath, http.FS(fsys), DefaultStaticConfig())
+}
+
+// 把单个文件映射到一个路由上, 例如 /favicon.ico
+func (rg *RouterGroup) StaticFile(relativePath string, filepath string) {
+	if strings.Contains(relativePath, ":") || strings.Contains(relativePath, "*") {
+		panic("gee: URL parameters can not be used when serving a static file")
+	}
+	rg.GET(relativePath, func(c *Context) {
+		http.ServeFile(c.Writer, c.Req, filepath)
+	})
+}
+
+// 注册 relativePath/*filepath 路由, 从root中读取文件
+func (rg *RouterGroup) StaticWithConfig(relativePath string, root http.FileSystem, config StaticConfig) {
+	handler := rg.createStaticHandler(relativePath, root, config)
+	urlPattern := path.Join(relativePath, "/*filepath")
+	rg.GET(urlPattern, handler)
+}
+
+func (rg *RouterGroup) createStaticHandler(relativePath string, root http.FileSystem, config StaticConfig) HandlerFunc {
+	// 获取绝对路径, 列出目录时交给http.FileServer
+	absolutePath := path.Join(rg.prefix, relativePath)
+	fileServer := http.StripPrefix(absolutePath, http.FileServer(root))
+	etags := &etagCache{}
+
+	return func(c *Context) {
+		name := path.Clean("/" + c.Params["filepath"])
+		f, info, err := openStatic(root, name)
+		if err == nil && info.IsDir() {
+			f.Close()
+			f = nil
+			// 目录需要以 / 结尾, 否则页面里的相对路径会出错
+			if !strings.HasSuffix(c.Req.URL.Path, "/") {
+				c.SetHeader("Location", path.Base(c.Req.URL.Path)+"/")
+				c.Status(http.StatusMovedPermanently)
+				return
+			}
+			if config.Index != "" {
+				name = path.Join(name, config.Index)
+				f, info, err = openStatic(root, name)
+			}
+			if f == nil && config.Browse {
+				fileServer.ServeHTTP(c.Writer, c.Req)
+				return
+			}
+			if f == nil {
+				err = os.ErrNotExist
+			}
+		}
+		if err != nil && config.SPAFallback != "" {
+			name = path.Clean("/" + config.SPAFallback)
+			f, info, err = openStatic(root, name)
+		}
+		if err != nil {
+			c.String(http.StatusNotFound, "404 NOT FOUND: %s\n", c.Path)
+			return
+		}
+		defer f.Close()
+
+		var content io.ReadSeeker = f
+		header := c.Writer.Header()
+		if config.Precompressed && strings.Contains(c.Req.Header.Get("Accept-Encoding"), "gzip") {
+			if gz, gzInfo, err := openStatic(root, name+".gz"); err == nil && !gzInfo.IsDir() {
+				defer gz.Close()
+				ctype := mime.TypeByExtension(path.Ext(name))
+				if ctype == "" {
+					ctype = "application/octet-stream"
+				}
+				header.Set("Content-Type", ctype)
+				header.Set("Content-Encoding", "gzip")
+				header.Add("Vary", "Accept-Encoding")
+				content, info = gz, gzInfo
+				name += ".gz"
+			}
+		}
+		if config.CacheControl != "" {
+			header.Set("Cache-Control", config.CacheControl)
+		}
+		if config.ETag {
+			if etag := etags.get(name, info, content); etag != "" {
+				header.Set("ETag", etag)
+			}
+		}
+		// ServeContent会处理 If-None-Match / If-Modified-Since 和 Range
+		http.ServeContent(c.Writer, c.Req, info.Name(), info.ModTime(), content)
+	}
+}
+
+// 打开文件并获取文件信息, 出错时会关闭文件
+func openStatic(root http.FileSystem, name string) (http.File, os.FileInfo, error) {
+	f, err := root.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, info, nil
+}
+
+// 缓存文件的ETag
+// 有修改时间的文件用 大小+修改时间 生成弱ETag
+// go:embed的文件没有修改时间, 用内容的sha256生成强ETag, 只计算一次
+type etagCache struct {
+	hashes sync.Map
+}
+
+func (ec *etagCache) get(name string, info os.FileInfo, content io.ReadSeeker) string {
+	if !info.ModTime().IsZero() {
+		return fmt.Sprintf(`W/"%x-%x"`, info.Size(), info.ModTime().UnixNano())
+	}
+	if etag, ok := ec.hashes.Load(name); ok {
+		return etag.(string)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return ""
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return ""
+	}
+	etag := `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+	ec.hashes.Store(name, etag)
+	return etag
+}
@@ -0,0 +1,65 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestStaticWithConfig(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html":      {Data: []byte("<h1>spa</h1>")},
+		"css/main.css":    {Data: []byte("body{}")},
+		"css/main.css.gz": {Data: []byte("gzipped")},
+		"docs/index.html": {Data: []byte("docs")},
+	}
+	r := New()
+	config := DefaultStaticConfig()
+	config.SPAFallback = "index.html"
+	config.Precompressed = true
+	config.CacheControl = "public, max-age=60"
+	r.StaticWithConfig("/assets", http.FS(fsys), config)
+
+	do := func(path string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("/assets/css/main.css")
+	etag := w.Header().Get("ETag")
+	if w.Body.String() != "body{}" || etag == "" || w.Header().Get("Cache-Control") != "public, max-age=60" {
+		t.Fatalf("unexpected response: %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+	if w = do("/assets/css/main.css", "If-None-Match", etag); w.Code != http.StatusNotModified {
+		t.Fatalf("expect 304, got %d", w.Code)
+	}
+	w = do("/assets/css/main.css", "Accept-Encoding", "gzip, br")
+	if w.Body.String() != "gzipped" || w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Content-Type") != "text/css; charset=utf-8" {
+		t.Fatalf("expect precompressed asset, got %q %v", w.Body.String(), w.Header())
+	}
+	if w = do("/assets/docs/"); w.Body.String() != "docs" {
+		t.Fatalf("expect index file, got %d %q", w.Code, w.Body.String())
+	}
+	if w = do("/assets/docs"); w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "docs/" {
+		t.Fatalf("expect redirect to docs/, got %d", w.Code)
+	}
+	if w = do("/assets/app/settings"); w.Body.String() != "<h1>spa</h1>" {
+		t.Fatalf("expect spa fallback, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestStaticNoBrowse(t *testing.T) {
+	r := New()
+	r.StaticFS("/assets", fstest.MapFS{"css/main.css": {Data: []byte("body{}")}})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/assets/css/", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("directory listing should be disabled, got %d", w.Code)
+	}
+}
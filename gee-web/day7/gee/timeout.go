@@ -0,0 +1,183 @@
+// 请求超时中间件
+package gee
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type TimeoutConfig struct {
+	// 超时时间, 必填
+	Timeout time.Duration
+	// 超时后的状态码, 默认503, 也可以设置为504
+	StatusCode int
+	// 超时后的响应内容
+	Body string
+	// 响应内容的类型, 默认 text/plain
+	ContentType string
+}
+
+// 超时中间件, 超时后返回503
+func Timeout(d time.Duration) HandlerFunc {
+	return TimeoutWithConfig(TimeoutConfig{Timeout: d})
+}
+
+// 后面的中间件和业务函数在新的goroutine里执行, 响应先写到缓冲区里
+// 超时后返回配置好的响应, 业务函数之后的写入都会返回 http.ErrHandlerTimeout
+// 业务函数可以通过 c.Req.Context().Done() 感知到超时
+func TimeoutWithConfig(config TimeoutConfig) HandlerFunc {
+	if config.Timeout <= 0 {
+		panic("gee: Timeout needs a positive duration")
+	}
+	if config.StatusCode == 0 {
+		config.StatusCode = http.StatusServiceUnavailable
+	}
+	if config.Body == "" {
+		config.Body = http.StatusText(config.StatusCode)
+	}
+	if config.ContentType == "" {
+		config.ContentType = "text/plain; charset=utf-8"
+	}
+	return func(c *Context) {
+		// 先标记超时再取消ctx, 保证业务函数感知到取消时已经无法写入
+		deadline := time.Now().Add(config.Timeout)
+		ctx, cancel := context.WithCancel(c.Req.Context())
+		defer cancel()
+		timer := time.NewTimer(config.Timeout)
+		defer timer.Stop()
+
+		tw := &timeoutWriter{header: make(http.Header)}
+		// 后面的函数使用一个新的上下文, 超时之后它们再怎么修改都不会影响到c
+		tc := &Context{
+			Writer:   tw,
+			Req:      c.Req.WithContext(deadlineContext{ctx, deadline}),
+			Path:     c.Path,
+			Method:   c.Method,
+			Params:   c.Params,
+			fullPath: c.fullPath,
+			index:    c.index,
+			handlers: c.handlers,
+			engine:   c.engine,
+			Keys:     c.copyKeys(),
+		}
+
+		done := make(chan struct{})
+		panicChan := make(chan interface{}, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicChan <- p
+				}
+			}()
+			tc.Next()
+			close(done)
+		}()
+
+		select {
+		case p := <-panicChan:
+			// 交给外层的Recover中间件处理
+			c.Abort()
+			panic(p)
+		case <-done:
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			dst := c.Writer.Header()
+			for k, v := range tw.header {
+				dst[k] = v
+			}
+			if tw.code != 0 {
+				c.Status(tw.code)
+			}
+			c.Writer.Write(tw.buf.Bytes())
+			c.Keys = tc.copyKeys()
+			c.Errors = append(c.Errors, tc.Errors...)
+			// 后面的函数已经执行完了, 外层的Next不需要再执行它们
+			c.index = tc.index
+		case <-ctx.Done():
+			// 客户端主动断开, 不需要响应
+			tw.stop()
+			c.Abort()
+		case <-timer.C:
+			tw.stop()
+			cancel()
+			c.Abort()
+			c.SetHeader("Content-Type", config.ContentType)
+			c.Data(config.StatusCode, []byte(config.Body))
+		}
+	}
+}
+
+// 拷贝一份上下文中的数据
+func (c *Context) copyKeys() map[string]interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.Keys == nil {
+		return nil
+	}
+	keys := make(map[string]interface{}, len(c.Keys))
+	for k, v := range c.Keys {
+		keys[k] = v
+	}
+	return keys
+}
+
+// 取消的原因和截止时间交给Timeout中间件决定
+type deadlineContext struct {
+	context.Context
+	deadline time.Time
+}
+
+func (ctx deadlineContext) Deadline() (time.Time, bool) {
+	return ctx.deadline, true
+}
+
+func (ctx deadlineContext) Err() error {
+	if err := ctx.Context.Err(); err != nil && time.Now().After(ctx.deadline) {
+		return context.DeadlineExceeded
+	}
+	return ctx.Context.Err()
+}
+
+// 把响应缓存起来, 超时之后拒绝写入
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	buf      bytes.Buffer
+	code     int
+	timedOut bool
+}
+
+// 超时之后的写入都会失败
+func (tw *timeoutWriter) stop() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.timedOut = true
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+	return tw.buf.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.code != 0 {
+		return
+	}
+	tw.code = code
+}
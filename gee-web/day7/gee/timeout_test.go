@@ -0,0 +1,38 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	r := New()
+	r.Use(TimeoutWithConfig(TimeoutConfig{Timeout: 50 * time.Millisecond, StatusCode: http.StatusGatewayTimeout}))
+	late := make(chan error, 1)
+	r.GET("/slow", func(c *Context) {
+		<-c.Req.Context().Done()
+		_, err := c.Writer.Write([]byte("too late"))
+		late <- err
+	})
+	r.GET("/fast", func(c *Context) {
+		c.SetHeader("X-Gee", "fast")
+		c.String(http.StatusCreated, "ok")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+	if w.Code != http.StatusGatewayTimeout || w.Body.String() != "Gateway Timeout" {
+		t.Fatalf("expect 504, got %d %q", w.Code, w.Body.String())
+	}
+	if err := <-late; err != http.ErrHandlerTimeout {
+		t.Fatalf("late write should fail, got %v", err)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/fast", nil))
+	if w.Code != http.StatusCreated || w.Body.String() != "ok" || w.Header().Get("X-Gee") != "fast" {
+		t.Fatalf("expect buffered response, got %d %q", w.Code, w.Body.String())
+	}
+}
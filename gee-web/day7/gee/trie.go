@@ -26,14 +26,9 @@ func (n *node) String() string {
 // 向Trie树里面插入
 // 可以看作构建前缀树的过程 
 
-// 这里面有一个bug
-// 假设第一次注册的路由是 /p/:lang/doc
-// 然后又注册了一个路由 /p/go/doc
-// 那么最后一个结点的pattern就会由 "/p/:lang/doc" 变为 "/p/go/doc"
-// 虽然，当一个请求 /p/python/doc来到时依然会去匹配到这个路由， 但是 在router.go 100行 生成key时就会有些问题
-// 这个时候生成的key是 ”GET-"/p/go/doc" 而不是 ”GET-"/p/：lang/doc“
-// 此时就会把新到来的处理函数“错误绑定”， 变为{”GET-"/p/go/doc": handlePython}
-// 而不是 {”GET-"/p/：lang/doc": handlePython}
+// 插入时只复用part完全相同的子节点
+// 以前模糊匹配的子节点也会被复用, 先注册 /p/:lang/doc 再注册 /p/go/doc 时
+// 最后一个结点的pattern会被覆盖为 "/p/go/doc", /p/python/doc 也会交给 /p/go/doc 的处理函数
 func (n *node) insert(pattern string, parts []string, height int) {
 	if len(parts) == height { // 说明插入到了最后一个part，此时pattern构造完毕
 		n.pattern = pattern // 构造完毕
@@ -77,13 +72,12 @@ func (n *node) search(parts []string, height int) *node {
 	return nil
 }
 
-// 匹配child, 返回找到的结点
+// 匹配child, 返回找到的结点, 用于插入
+// 只做精确匹配, 模糊匹配只在查找时使用
 func (n *node) matchChild(part string) *node {
-	// 根据part来进行查找
-
 	// 获取n所有的孩子结点
 	for _, child := range n.child {
-		if child.part == part || child.isWiled { // 精确匹配或者模糊匹配
+		if child.part == part {
 			return child
 		}
 	}
@@ -91,15 +85,22 @@ func (n *node) matchChild(part string) *node {
 }
 
 // 所有匹配成功的节点，用于查找
+// 按 精确匹配, :参数, *通配 的顺序返回, 和注册的顺序无关
 func (n *node) matchChildren(part string) []*node {
 	// 获取所有的part的子路径
 	res := make([]*node, 0)
-
+	var params, catchAll []*node
 	for _, child := range n.child {
-		if child.part == part || child.isWiled {
+		switch {
+		case child.part == part:
 			res = append(res, child)
+		case child.isWiled && child.part[0] == '*':
+			catchAll = append(catchAll, child)
+		case child.isWiled:
+			params = append(params, child)
 		}
 	}
 	// 返回所有匹配的路径
-	return res
+	res = append(res, params...)
+	return append(res, catchAll...)
 }
\ No newline at end of file
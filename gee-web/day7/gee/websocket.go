@@ -0,0 +1,501 @@
+// WebSocket (RFC 6455) 实现, 只依赖标准库
+// 包括握手、帧的读写、掩码、ping/pong、关闭码和分片消息
+package gee
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 消息类型, 与帧的opcode一致
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+)
+
+const continuationFrame = 0
+
+// 关闭码, 见 RFC 6455 7.4.1
+const (
+	CloseNormalClosure       = 1000
+	CloseGoingAway           = 1001
+	CloseProtocolError       = 1002
+	CloseUnsupportedData     = 1003
+	CloseNoStatusReceived    = 1005
+	CloseAbnormalClosure     = 1006
+	CloseInvalidFramePayload = 1007
+	ClosePolicyViolation     = 1008
+	CloseMessageTooBig       = 1009
+	CloseMandatoryExtension  = 1010
+	CloseInternalServerErr   = 1011
+)
+
+// 握手时用来计算 Sec-WebSocket-Accept 的固定GUID
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var (
+	ErrWebSocketHandshake = errors.New("gee: websocket handshake failed")
+	ErrCloseSent          = errors.New("gee: websocket close frame has been sent")
+)
+
+// 收到关闭帧或者因为协议错误关闭连接时返回的错误
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("gee: websocket closed: %d %s", e.Code, e.Text)
+}
+
+// 处理WebSocket连接的函数, 返回后连接会被关闭
+type WebSocketHandler func(c *Context, conn *WSConn)
+
+type WebSocketConfig struct {
+	// 检查Origin, 默认只允许没有Origin或者Origin与Host相同的请求
+	CheckOrigin func(r *http.Request) bool
+	// 服务端支持的子协议, 按顺序选择第一个客户端也支持的
+	Subprotocols []string
+	// 单条消息的最大字节数, 默认32MB
+	ReadLimit int64
+}
+
+// 注册WebSocket路由, 路由组的中间件和动态参数都可以正常使用
+func (rg *RouterGroup) WebSocket(pattern string, handler WebSocketHandler) {
+	rg.WebSocketWithConfig(pattern, WebSocketConfig{}, handler)
+}
+
+func (rg *RouterGroup) WebSocketWithConfig(pattern string, config WebSocketConfig, handler WebSocketHandler) {
+	rg.GET(pattern, func(c *Context) {
+		conn, err := UpgradeWebSocket(c, config)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handler(c, conn)
+	})
+}
+
+// 完成握手, 把http连接升级为WebSocket连接
+// 握手失败时已经写好了错误响应
+func UpgradeWebSocket(c *Context, config WebSocketConfig) (*WSConn, error) {
+	if config.CheckOrigin == nil {
+		config.CheckOrigin = sameOrigin
+	}
+	if config.ReadLimit <= 0 {
+		config.ReadLimit = 32 << 20
+	}
+	req := c.Req
+	key := req.Header.Get("Sec-WebSocket-Key")
+	switch {
+	case req.Method != http.MethodGet,
+		!headerContainsToken(req.Header, "Connection", "upgrade"),
+		!headerContainsToken(req.Header, "Upgrade", "websocket"):
+		c.Fail(http.StatusBadRequest, "not a websocket handshake")
+		return nil, ErrWebSocketHandshake
+	case req.Header.Get("Sec-WebSocket-Version") != "13":
+		c.SetHeader("Sec-WebSocket-Version", "13")
+		c.Fail(http.StatusUpgradeRequired, "unsupported websocket version")
+		return nil, ErrWebSocketHandshake
+	case !validWebSocketKey(key):
+		c.Fail(http.StatusBadRequest, "invalid Sec-WebSocket-Key")
+		return nil, ErrWebSocketHandshake
+	case !config.CheckOrigin(req):
+		c.Fail(http.StatusForbidden, "origin not allowed")
+		return nil, ErrWebSocketHandshake
+	}
+
+	subprotocol := selectSubprotocol(req, config.Subprotocols)
+	netConn, brw, err := http.NewResponseController(c.Writer).Hijack()
+	if err != nil {
+		c.Fail(http.StatusInternalServerError, "websocket: connection can not be hijacked")
+		return nil, err
+	}
+	// 握手之前客户端不应该发送数据, 有残留说明不是合法的客户端
+	if brw.Reader.Buffered() > 0 {
+		netConn.Close()
+		return nil, ErrWebSocketHandshake
+	}
+
+	var b strings.Builder
+	b.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	b.WriteString("Sec-WebSocket-Accept: " + websocketAccept(key) + "\r\n")
+	if subprotocol != "" {
+		b.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	b.WriteString("\r\n")
+	if _, err := netConn.Write([]byte(b.String())); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	c.StatusCode = http.StatusSwitchingProtocols
+	// 握手时设置的超时不再适用
+	netConn.SetDeadline(time.Time{})
+	return newWSConn(netConn, brw.Reader, subprotocol, config.ReadLimit), nil
+}
+
+// 服务端的WebSocket连接
+// 同一时间只能有一个goroutine读, 写操作可以并发
+type WSConn struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	subprotocol string
+	readLimit   int64
+
+	// 写锁, 控制帧和数据帧都要加锁
+	wmu       sync.Mutex
+	closeSent bool
+
+	// 正在接收的分片消息
+	fragType int
+	fragBuf  []byte
+
+	// 收到pong时的回调
+	pongHandler func(data []byte)
+}
+
+func newWSConn(conn net.Conn, br *bufio.Reader, subprotocol string, readLimit int64) *WSConn {
+	return &WSConn{conn: conn, br: br, subprotocol: subprotocol, readLimit: readLimit}
+}
+
+// 握手时协商出的子协议
+func (ws *WSConn) Subprotocol() string {
+	return ws.subprotocol
+}
+
+func (ws *WSConn) RemoteAddr() net.Addr {
+	return ws.conn.RemoteAddr()
+}
+
+func (ws *WSConn) SetReadDeadline(t time.Time) error {
+	return ws.conn.SetReadDeadline(t)
+}
+
+func (ws *WSConn) SetWriteDeadline(t time.Time) error {
+	return ws.conn.SetWriteDeadline(t)
+}
+
+// 设置收到pong时的回调, 可以用来延长读超时
+func (ws *WSConn) SetPongHandler(h func(data []byte)) {
+	ws.pongHandler = h
+}
+
+// 读取一条完整的消息, 分片消息会被拼接起来
+// 收到的ping会自动回复pong, 收到关闭帧时回复关闭帧并返回 *CloseError
+func (ws *WSConn) ReadMessage() (messageType int, data []byte, err error) {
+	for {
+		fin, op, payload, err := ws.readFrame()
+		if err != nil {
+			return 0, nil, ws.fail(err)
+		}
+		switch op {
+		case PingMessage:
+			if err := ws.writeFrame(PongMessage, payload); err != nil && err != ErrCloseSent {
+				return 0, nil, err
+			}
+		case PongMessage:
+			if ws.pongHandler != nil {
+				ws.pongHandler(payload)
+			}
+		case CloseMessage:
+			return 0, nil, ws.handleClose(payload)
+		case TextMessage, BinaryMessage:
+			if ws.fragType != 0 {
+				return 0, nil, ws.fail(&CloseError{CloseProtocolError, "expected continuation frame"})
+			}
+			if fin {
+				return ws.finishMessage(op, payload)
+			}
+			ws.fragType = op
+			ws.fragBuf = append(ws.fragBuf[:0], payload...)
+		case continuationFrame:
+			if ws.fragType == 0 {
+				return 0, nil, ws.fail(&CloseError{CloseProtocolError, "unexpected continuation frame"})
+			}
+			if int64(len(ws.fragBuf)+len(payload)) > ws.readLimit {
+				return 0, nil, ws.fail(&CloseError{CloseMessageTooBig, "message too big"})
+			}
+			ws.fragBuf = append(ws.fragBuf, payload...)
+			if fin {
+				op := ws.fragType
+				ws.fragType = 0
+				msg := ws.fragBuf
+				ws.fragBuf = nil
+				return ws.finishMessage(op, msg)
+			}
+		}
+	}
+}
+
+// 文本消息必须是合法的UTF-8
+func (ws *WSConn) finishMessage(op int, data []byte) (int, []byte, error) {
+	if op == TextMessage && !utf8.Valid(data) {
+		return 0, nil, ws.fail(&CloseError{CloseInvalidFramePayload, "invalid utf-8"})
+	}
+	return op, data, nil
+}
+
+// 发送一条消息, 不做分片
+func (ws *WSConn) WriteMessage(messageType int, data []byte) error {
+	switch messageType {
+	case TextMessage:
+		if !utf8.Valid(data) {
+			return errors.New("gee: websocket text message must be utf-8")
+		}
+	case BinaryMessage:
+	case PingMessage, PongMessage:
+		if len(data) > 125 {
+			return errors.New("gee: websocket control frame payload too long")
+		}
+	default:
+		return fmt.Errorf("gee: unsupported websocket message type %d", messageType)
+	}
+	return ws.writeFrame(messageType, data)
+}
+
+// 读取一条消息并解析为JSON
+func (ws *WSConn) ReadJSON(v interface{}) error {
+	_, data, err := ws.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// 以文本消息发送JSON
+func (ws *WSConn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ws.writeFrame(TextMessage, data)
+}
+
+// 发送ping
+func (ws *WSConn) Ping(data []byte) error {
+	return ws.WriteMessage(PingMessage, data)
+}
+
+// 发送关闭帧, 之后还需要继续读取, 直到收到对方的关闭帧
+func (ws *WSConn) WriteClose(code int, text string) error {
+	return ws.writeFrame(CloseMessage, closePayload(code, text))
+}
+
+// 直接关闭底层连接
+func (ws *WSConn) Close() error {
+	return ws.conn.Close()
+}
+
+// 读取一帧, 返回协议错误时使用 *CloseError
+func (ws *WSConn) readFrame() (fin bool, op int, payload []byte, err error) {
+	var head [2]byte
+	if _, err = io.ReadFull(ws.br, head[:]); err != nil {
+		return
+	}
+	fin = head[0]&0x80 != 0
+	op = int(head[0] & 0x0f)
+	masked := head[1]&0x80 != 0
+	length := int64(head[1] & 0x7f)
+
+	// 没有协商扩展, RSV必须为0
+	if head[0]&0x70 != 0 {
+		return fin, op, nil, &CloseError{CloseProtocolError, "reserved bits set"}
+	}
+	switch op {
+	case continuationFrame, TextMessage, BinaryMessage:
+	case CloseMessage, PingMessage, PongMessage:
+		// 控制帧不能分片, 长度不超过125
+		if !fin || length > 125 {
+			return fin, op, nil, &CloseError{CloseProtocolError, "invalid control frame"}
+		}
+	default:
+		return fin, op, nil, &CloseError{CloseProtocolError, "reserved opcode"}
+	}
+	// 客户端发送的帧必须有掩码
+	if !masked {
+		return fin, op, nil, &CloseError{CloseProtocolError, "frame not masked"}
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(ws.br, ext[:]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(ws.br, ext[:]); err != nil {
+			return
+		}
+		if ext[0]&0x80 != 0 {
+			return fin, op, nil, &CloseError{CloseProtocolError, "invalid payload length"}
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+	if length > ws.readLimit {
+		return fin, op, nil, &CloseError{CloseMessageTooBig, "message too big"}
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(ws.br, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(ws.br, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, op, payload, nil
+}
+
+// 写一帧, 服务端发送的帧不加掩码
+func (ws *WSConn) writeFrame(op int, payload []byte) error {
+	ws.wmu.Lock()
+	defer ws.wmu.Unlock()
+	if ws.closeSent {
+		return ErrCloseSent
+	}
+	if op == CloseMessage {
+		ws.closeSent = true
+	}
+	header := make([]byte, 2, 10)
+	header[0] = 0x80 | byte(op)
+	switch n := len(payload); {
+	case n <= 125:
+		header[1] = byte(n)
+	case n <= 0xffff:
+		header[1] = 126
+		header = append(header, byte(n>>8), byte(n))
+	default:
+		header[1] = 127
+		var ext [8]byte
+		binary.BigEndian.PutUint64(ext[:], uint64(n))
+		header = append(header, ext[:]...)
+	}
+	buffers := net.Buffers{header, payload}
+	_, err := buffers.WriteTo(ws.conn)
+	return err
+}
+
+// 收到关闭帧: 校验关闭码, 回复同样的关闭码
+func (ws *WSConn) handleClose(payload []byte) error {
+	closeErr := &CloseError{Code: CloseNoStatusReceived}
+	switch {
+	case len(payload) == 1:
+		return ws.fail(&CloseError{CloseProtocolError, "invalid close payload"})
+	case len(payload) >= 2:
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Text = string(payload[2:])
+		if !validCloseCode(closeErr.Code) {
+			return ws.fail(&CloseError{CloseProtocolError, "invalid close code"})
+		}
+		if !utf8.Valid(payload[2:]) {
+			return ws.fail(&CloseError{CloseInvalidFramePayload, "invalid utf-8 in close reason"})
+		}
+	}
+	reply := closeErr.Code
+	if reply == CloseNoStatusReceived {
+		reply = CloseNormalClosure
+	}
+	ws.WriteClose(reply, "")
+	ws.conn.Close()
+	return closeErr
+}
+
+// 协议错误时发送关闭帧并断开连接
+func (ws *WSConn) fail(err error) error {
+	if ce, ok := err.(*CloseError); ok {
+		ws.WriteClose(ce.Code, ce.Text)
+	}
+	ws.conn.Close()
+	return err
+}
+
+// 可以出现在关闭帧里的关闭码
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+func closePayload(code int, text string) []byte {
+	if code == CloseNoStatusReceived {
+		return nil
+	}
+	// 控制帧长度不超过125
+	if len(text) > 123 {
+		text = text[:123]
+	}
+	buf := make([]byte, 2+len(text))
+	binary.BigEndian.PutUint16(buf, uint16(code))
+	copy(buf[2:], text)
+	return buf
+}
+
+func websocketAccept(key string) string {
+	h := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// key必须是16字节随机数的base64编码
+func validWebSocketKey(key string) bool {
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	return err == nil && len(decoded) == 16
+}
+
+// 判断请求头里是否包含某个token, 例如 Connection: keep-alive, Upgrade
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, v := range header[name] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func selectSubprotocol(req *http.Request, supported []string) string {
+	for _, s := range supported {
+		if headerContainsToken(req.Header, "Sec-WebSocket-Protocol", s) {
+			return s
+		}
+	}
+	return ""
+}
+
+// 浏览器发起的跨域WebSocket请求默认拒绝
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
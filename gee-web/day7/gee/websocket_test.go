@@ -0,0 +1,238 @@
+package gee
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 参考autobahn测试套件的用例, 用一个最简单的客户端直接收发原始帧
+
+type wsTestClient struct {
+	t    *testing.T
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func newEchoServer(t *testing.T) *httptest.Server {
+	r := New()
+	r.WebSocket("/echo/:room", func(c *Context, conn *WSConn) {
+		for {
+			op, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if op == TextMessage && string(data) == "room" {
+				data = []byte(c.Param("room"))
+			}
+			if err := conn.WriteMessage(op, data); err != nil {
+				return
+			}
+		}
+	})
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func dialWS(t *testing.T, srv *httptest.Server) *wsTestClient {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	req := "GET /echo/gee HTTP/1.1\r\nHost: gee\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := conn.Write([]byte(req)); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// RFC 6455 1.3 中的例子
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected handshake response: %d %v", resp.StatusCode, resp.Header)
+	}
+	return &wsTestClient{t: t, conn: conn, br: br}
+}
+
+// 发送一帧, masked为false时模拟不合规的客户端
+func (wc *wsTestClient) send(b0 byte, payload []byte, masked bool) {
+	var buf bytes.Buffer
+	buf.WriteByte(b0)
+	maskBit := byte(0)
+	if masked {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		buf.WriteByte(maskBit | byte(n))
+	case n <= 0xffff:
+		buf.WriteByte(maskBit | 126)
+		binary.Write(&buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(maskBit | 127)
+		binary.Write(&buf, binary.BigEndian, uint64(n))
+	}
+	data := append([]byte(nil), payload...)
+	if masked {
+		mask := []byte{0x12, 0x34, 0x56, 0x78}
+		buf.Write(mask)
+		for i := range data {
+			data[i] ^= mask[i%4]
+		}
+	}
+	buf.Write(data)
+	if _, err := wc.conn.Write(buf.Bytes()); err != nil {
+		wc.t.Fatal(err)
+	}
+}
+
+func (wc *wsTestClient) recv() (op int, payload []byte) {
+	var head [2]byte
+	if _, err := io.ReadFull(wc.br, head[:]); err != nil {
+		wc.t.Fatal(err)
+	}
+	if head[1]&0x80 != 0 {
+		wc.t.Fatal("server frames must not be masked")
+	}
+	n := uint64(head[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext uint16
+		binary.Read(wc.br, binary.BigEndian, &ext)
+		n = uint64(ext)
+	case 127:
+		binary.Read(wc.br, binary.BigEndian, &n)
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(wc.br, payload); err != nil {
+		wc.t.Fatal(err)
+	}
+	return int(head[0] & 0x0f), payload
+}
+
+func (wc *wsTestClient) expect(op int, payload []byte) {
+	gotOp, got := wc.recv()
+	if gotOp != op || !bytes.Equal(got, payload) {
+		wc.t.Fatalf("expect op=%d payload=%q, got op=%d payload=%q", op, payload, gotOp, got)
+	}
+}
+
+func (wc *wsTestClient) expectClose(code int) {
+	op, payload := wc.recv()
+	if op != CloseMessage || len(payload) < 2 || int(binary.BigEndian.Uint16(payload)) != code {
+		wc.t.Fatalf("expect close %d, got op=%d payload=%q", code, op, payload)
+	}
+}
+
+func TestWebSocketEcho(t *testing.T) {
+	srv := newEchoServer(t)
+	wc := dialWS(t, srv)
+
+	wc.send(0x81, []byte("Hello-µ@ßöäüàá-UTF-8!!"), true)
+	wc.expect(TextMessage, []byte("Hello-µ@ßöäüàá-UTF-8!!"))
+	// 路由参数可以在handler里使用
+	wc.send(0x81, []byte("room"), true)
+	wc.expect(TextMessage, []byte("gee"))
+
+	// 16位和64位长度
+	for _, n := range []int{126, 65535, 65536} {
+		payload := bytes.Repeat([]byte{0xfe}, n)
+		wc.send(0x82, payload, true)
+		wc.expect(BinaryMessage, payload)
+	}
+
+	wc.send(0x89, []byte("ping"), true)
+	wc.expect(PongMessage, []byte("ping"))
+
+	wc.send(0x88, []byte{0x03, 0xe8}, true)
+	wc.expectClose(CloseNormalClosure)
+}
+
+func TestWebSocketFragmentation(t *testing.T) {
+	srv := newEchoServer(t)
+	wc := dialWS(t, srv)
+
+	// 分片之间穿插控制帧
+	wc.send(0x01, []byte("frag"), true)
+	wc.send(0x89, []byte("p"), true)
+	wc.send(0x00, []byte("men"), true)
+	wc.send(0x80, []byte("ted"), true)
+	wc.expect(PongMessage, []byte("p"))
+	wc.expect(TextMessage, []byte("fragmented"))
+
+	// UTF-8字符被拆在两个分片里
+	euro := []byte("€")
+	wc.send(0x01, euro[:1], true)
+	wc.send(0x80, euro[1:], true)
+	wc.expect(TextMessage, euro)
+}
+
+func TestWebSocketProtocolErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		send  func(wc *wsTestClient)
+		close int
+	}{
+		{"unmasked frame", func(wc *wsTestClient) { wc.send(0x81, []byte("hi"), false) }, CloseProtocolError},
+		{"reserved bits", func(wc *wsTestClient) { wc.send(0xc1, []byte("hi"), true) }, CloseProtocolError},
+		{"reserved opcode", func(wc *wsTestClient) { wc.send(0x83, nil, true) }, CloseProtocolError},
+		{"fragmented ping", func(wc *wsTestClient) { wc.send(0x09, nil, true) }, CloseProtocolError},
+		{"long ping", func(wc *wsTestClient) { wc.send(0x89, make([]byte, 126), true) }, CloseProtocolError},
+		{"orphan continuation", func(wc *wsTestClient) { wc.send(0x80, []byte("x"), true) }, CloseProtocolError},
+		{"nested message", func(wc *wsTestClient) {
+			wc.send(0x01, []byte("a"), true)
+			wc.send(0x81, []byte("b"), true)
+		}, CloseProtocolError},
+		{"invalid utf-8", func(wc *wsTestClient) { wc.send(0x81, []byte{0xce, 0xba, 0xe1, 0xbd}, true) }, CloseInvalidFramePayload},
+		{"close payload of 1 byte", func(wc *wsTestClient) { wc.send(0x88, []byte{0x03}, true) }, CloseProtocolError},
+		{"invalid close code", func(wc *wsTestClient) { wc.send(0x88, []byte{0x03, 0xed}, true) }, CloseProtocolError},
+	}
+	srv := newEchoServer(t)
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			wc := dialWS(t, srv)
+			c.send(wc)
+			wc.expectClose(c.close)
+		})
+	}
+}
+
+func TestWebSocketHandshakeRejected(t *testing.T) {
+	srv := newEchoServer(t)
+	req, _ := http.NewRequest("GET", srv.URL+"/echo/gee", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "8")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUpgradeRequired || resp.Header.Get("Sec-WebSocket-Version") != "13" {
+		t.Fatalf("expect 426, got %d", resp.StatusCode)
+	}
+
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Origin", "http://evil.example.com")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("cross origin handshake should be rejected, got %d", resp.StatusCode)
+	}
+}
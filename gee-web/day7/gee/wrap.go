@@ -0,0 +1,119 @@
+// 和net/http互相转换: 在gee里使用http.Handler, 或者用gee的中间件包装http.Handler
+package gee
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Mount挂载的路由中, 保存剩余路径的参数名
+const mountParam = "mountpath"
+
+// 所有的请求方式, Mount时每种都要注册
+var anyMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace,
+}
+
+// 把http.Handler转换为HandlerFunc
+func WrapH(h http.Handler) HandlerFunc {
+	return func(c *Context) {
+		h.ServeHTTP(c.Writer, c.Req)
+	}
+}
+
+// 把http.HandlerFunc转换为HandlerFunc
+func WrapF(f http.HandlerFunc) HandlerFunc {
+	return WrapH(f)
+}
+
+// 把http.Handler(例如pprof, promhttp, 或者另一个Engine)挂载到prefix下
+// 所有请求方式都会交给h处理, h看到的路径去掉了路由组和prefix的前缀
+// 路由组的中间件对挂载的handler同样生效
+//
+//	r.Mount("/debug", http.DefaultServeMux)
+//	r.Group("/v2").Mount("/api", apiEngine)
+func (rg *RouterGroup) Mount(prefix string, h http.Handler) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	handler := func(c *Context) {
+		req := stripMountPrefix(c.Req, c.Param(mountParam))
+		h.ServeHTTP(c.Writer, req)
+	}
+	// Mount("/", h) 时去掉 / 之后是空字符串, 路由树匹配不到空的路由规则
+	root := prefix
+	if root == "" {
+		root = "/"
+	}
+	for _, method := range anyMethods {
+		// 挂载的handler不出现在文档里
+		rg.addRouter(method, root, handler).Hidden()
+		rg.addRouter(method, prefix+"/*"+mountParam, handler).Hidden()
+	}
+}
+
+// 复制一份请求, 路径替换为挂载点之后的部分
+func stripMountPrefix(req *http.Request, rest string) *http.Request {
+	path := "/" + rest
+	// 路由树会丢掉结尾的 /, 这里补回来, 例如 /debug/pprof/
+	if rest != "" && strings.HasSuffix(req.URL.Path, "/") {
+		path += "/"
+	}
+	r2 := new(http.Request)
+	*r2 = *req
+	r2.URL = new(url.URL)
+	*r2.URL = *req.URL
+	r2.URL.Path = path
+	r2.URL.RawPath = ""
+	return r2
+}
+
+// 用gee的中间件包装一个http.Handler, 可以在不使用Engine的地方复用中间件
+// 中间件都调用Next之后才会执行h, 被Abort时h不会执行
+// 这里的上下文没有engine, 不能渲染模板
+//
+//	http.Handle("/metrics", gee.WrapHandler(promhttp.Handler(), gee.BasicAuth(accounts)))
+func WrapHandler(h http.Handler, middlewares ...HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		c := NewContext(w, req)
+		c.handlers = make([]HandlerFunc, 0, len(middlewares)+1)
+		c.handlers = append(c.handlers, middlewares...)
+		c.handlers = append(c.handlers, WrapH(h))
+		c.Next()
+	})
+}
+
+// WrapMiddleware时通过请求的context找到当前的上下文
+type wrapContextKey struct{}
+
+type wrapCall struct {
+	c      *Context
+	called bool
+}
+
+// 把标准的 func(http.Handler) http.Handler 中间件转换为HandlerFunc
+// mw只会被调用一次, 它调用next时执行后面的中间件和业务函数(即c.Next)
+// mw传给next的writer和request会替换c.Writer和c.Req, 在后面的函数中生效, mw返回后恢复原来的值
+// mw没有调用next时(例如认证失败), 后面的函数都不会执行
+//
+//	r.Use(gee.WrapMiddleware(handlers.CompressHandler))
+func WrapMiddleware(mw func(http.Handler) http.Handler) HandlerFunc {
+	h := mw(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		call := req.Context().Value(wrapContextKey{}).(*wrapCall)
+		call.called = true
+		c := call.c
+		c.Writer = w
+		c.Req = req
+		c.Next()
+	}))
+	return func(c *Context) {
+		w, req := c.Writer, c.Req
+		call := &wrapCall{c: c}
+		h.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), wrapContextKey{}, call)))
+		c.Writer, c.Req = w, req
+		if !call.called {
+			c.Abort()
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package gee
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMount(t *testing.T) {
+	sub := New()
+	sub.GET("/users/:id", func(c *Context) {
+		c.String(http.StatusOK, "user %s", c.Param("id"))
+	})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(req.Method + " " + req.URL.Path))
+	})
+
+	r := New()
+	v1 := r.Group("/v1")
+	v1.Use(func(c *Context) {
+		c.SetHeader("X-Group", "v1")
+	})
+	v1.Mount("/api", sub)
+	r.Mount("/raw/", mux)
+	// 挂载之后在前缀下注册的路由不能覆盖挂载的handler
+	r.GET("/raw/vars", func(c *Context) {
+		c.String(http.StatusOK, "vars")
+	})
+
+	tests := []struct {
+		method, path, body, group string
+	}{
+		{"GET", "/v1/api/users/1", "user 1", "v1"},
+		{"DELETE", "/raw/a/b/", "DELETE /a/b/", ""},
+		{"POST", "/raw", "POST /", ""},
+		{"GET", "/raw/vars", "vars", ""},
+		{"GET", "/raw/pprof/heap", "GET /pprof/heap", ""},
+		{"GET", "/raw/vars/x", "GET /vars/x", ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != http.StatusOK || w.Body.String() != tt.body {
+			t.Fatalf("%s %s: expect %q, got %d %q", tt.method, tt.path, tt.body, w.Code, w.Body.String())
+		}
+		if got := w.Header().Get("X-Group"); got != tt.group {
+			t.Fatalf("%s %s: expect X-Group %q, got %q", tt.method, tt.path, tt.group, got)
+		}
+	}
+
+	// 挂载到根路径, 子Engine的 / 也能访问到
+	sub.GET("/", func(c *Context) {
+		c.String(http.StatusOK, "index")
+	})
+	root := New()
+	root.Mount("/", sub)
+	root.GET("/hello", func(c *Context) {
+		c.String(http.StatusOK, "hello")
+	})
+	for path, body := range map[string]string{"/": "index", "/users/2": "user 2", "/hello": "hello"} {
+		w := httptest.NewRecorder()
+		root.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusOK || w.Body.String() != body {
+			t.Fatalf("root mount %s: expect %q, got %d %q", path, body, w.Code, w.Body.String())
+		}
+	}
+
+	// 挂载到根路径之后再注册路由, 其它路径依然交给挂载的handler
+	root = New()
+	root.Mount("/", mux)
+	root.GET("/hello", func(c *Context) {
+		c.String(http.StatusOK, "hello")
+	})
+	for path, body := range map[string]string{"/hello": "hello", "/x/y": "GET /x/y", "/": "GET /"} {
+		w := httptest.NewRecorder()
+		root.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusOK || w.Body.String() != body {
+			t.Fatalf("root mount %s: expect %q, got %d %q", path, body, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestWrapHandler(t *testing.T) {
+	h := WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("metrics"))
+	}), BasicAuth(Accounts{"admin": "1234"}))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expect 401, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	req.SetBasicAuth("admin", "1234")
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "metrics" {
+		t.Fatalf("expect 200 metrics, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+type upperWriter struct {
+	http.ResponseWriter
+}
+
+func (w upperWriter) Write(b []byte) (int, error) {
+	return w.ResponseWriter.Write(bytes.ToUpper(b))
+}
+
+type userKey struct{}
+
+func TestWrapMiddleware(t *testing.T) {
+	// 设置响应头后调用next
+	headerMW := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("X-Frame-Options", "DENY")
+			next.ServeHTTP(w, req)
+		})
+	}
+	// 替换writer
+	upperMW := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			next.ServeHTTP(upperWriter{w}, req)
+		})
+	}
+	// 替换request, 不满足条件时不调用next
+	authMW := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			user := req.Header.Get("X-User")
+			if user == "" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), userKey{}, user)))
+		})
+	}
+
+	var after []string
+	r := New()
+	r.Use(func(c *Context) {
+		c.Next()
+		after = append(after, fmt.Sprintf("aborted=%v", c.IsAborted()))
+	})
+	r.Use(WrapMiddleware(headerMW), WrapMiddleware(authMW), WrapMiddleware(upperMW))
+	r.GET("/hello", func(c *Context) {
+		c.String(http.StatusOK, "hello %s", c.Req.Context().Value(userKey{}))
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/hello", nil))
+	if w.Code != http.StatusUnauthorized || w.Header().Get("X-Frame-Options") != "DENY" {
+		t.Fatalf("expect 401 with header, got %d %v", w.Code, w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/hello", nil)
+	req.Header.Set("X-User", "geektutu")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "HELLO GEEKTUTU" {
+		t.Fatalf("expect 200 HELLO GEEKTUTU, got %d %q", w.Code, w.Body.String())
+	}
+	if len(after) != 2 || after[0] != "aborted=true" || after[1] != "aborted=false" {
+		t.Fatalf("unexpected abort state: %v", after)
+	}
+}
+
+func TestWrapMiddlewareRestoresContext(t *testing.T) {
+	stripMW := func(next http.Handler) http.Handler {
+		return http.StripPrefix("/api", next)
+	}
+	r := New()
+	var outerPath string
+	r.Use(func(c *Context) {
+		c.Next()
+		outerPath = c.Req.URL.Path
+	})
+	r.Use(WrapMiddleware(stripMW))
+	r.GET("/api/users", func(c *Context) {
+		c.String(http.StatusOK, "%s", c.Req.URL.Path)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/users", nil))
+	if w.Body.String() != "/users" || outerPath != "/api/users" {
+		t.Fatalf("expect inner /users and outer /api/users, got %q %q", w.Body.String(), outerPath)
+	}
+}
@@ -0,0 +1,38 @@
+package gee
+
+import "net/http"
+
+// 记录状态码和响应大小, 用来判断响应是否已经写出
+// 直接写c.Writer(例如 WrapH, http.ServeContent)时不会设置c.StatusCode, 需要通过它判断
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	// 1xx的响应之后还会有最终的状态码
+	if sw.status == 0 && code >= http.StatusOK {
+		sw.status = code
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Write(p []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	n, err := sw.ResponseWriter.Write(p)
+	sw.size += int64(n)
+	return n, err
+}
+
+// 是否已经写出了状态码或响应体
+func (sw *statusWriter) written() bool {
+	return sw.status != 0
+}
+
+// http.NewResponseController通过Unwrap找到原来的writer, 支持Flush和Hijack
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
@@ -1,3 +1,7 @@
 module example
+
+go 1.25
+
 require gee v0.0.0
-replace gee => ./gee
\ No newline at end of file
+
+replace gee => ./gee